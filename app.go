@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/asynccnu/be-ccnu/pkg/grpcx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/pkg/metricsx"
+	"github.com/asynccnu/be-ccnu/service"
+)
+
+// App 服务本身和需要跟着服务一起启停的后台任务
+type App struct {
+	server  grpcx.Server
+	keeper  *service.SessionKeeper
+	auditor *service.LoginAuditor
+	prober  *service.UpstreamProber
+	metrics *metricsx.Server
+	// calendar 配置文件修改后需要重新加载
+	calendar *service.AcademicCalendar
+	l        logger.Logger
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// Term 校历中的一个学期
+type Term struct {
+	// Year 学年的第一年，2024 表示 2024-2025 学年
+	Year string
+	// Term 1、2、3 分别是第一、第二学期和小学期
+	Term string
+	// Start 第一周的周一
+	Start time.Time
+	Weeks int
+}
+
+// End 最后一周周日的第二天零点
+func (t Term) End() time.Time {
+	return t.Start.AddDate(0, 0, t.Weeks*7)
+}
+
+// CalendarDay 某一天在校历中的位置
+type CalendarDay struct {
+	Date time.Time
+	Term Term
+	// Week 第几周，从 1 开始
+	Week int
+	// Weekday 星期几，1 是星期一，7 是星期日
+	Weekday     int
+	Holiday     bool
+	HolidayName string
+	// Makeup 这天调休上课，Week 和 Weekday 是按哪一天的课表上课
+	Makeup bool
+}
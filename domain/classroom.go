@@ -0,0 +1,24 @@
+package domain
+
+// FreeClassroomQuery 空闲教室的查询条件，Campus、Building 是教务系统里的校区和教学楼代码，Building 为空时查整个校区
+type FreeClassroomQuery struct {
+	Campus   string
+	Building string
+	Week     int
+	// Weekday 星期几，1 是星期一，7 是星期日
+	Weekday     int
+	StartPeriod int
+	EndPeriod   int
+}
+
+// Classroom 一间教室
+type Classroom struct {
+	Id       string
+	Name     string
+	Campus   string
+	Building string
+	// Capacity 座位数
+	Capacity int
+	// Type 教室类型，如 多媒体教室
+	Type string
+}
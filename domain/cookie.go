@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Cookie 上游系统下发的一个 Cookie
+type Cookie struct {
+	Name   string
+	Value  string
+	Domain string
+	Path   string
+	// ExpireAt 会话 Cookie 为零值
+	ExpireAt time.Time
+}
+
+// SystemCookies 一个上游系统（cas、jwglxt 或 yjsxt）的全部 Cookie
+type SystemCookies struct {
+	System  string
+	Cookies []Cookie
+}
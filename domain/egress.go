@@ -0,0 +1,15 @@
+package domain
+
+import "time"
+
+// EgressStats 请求上游的一个出口的状态，计数都是服务启动以来的累计值
+type EgressStats struct {
+	Name string
+	// Healthy 为 false 时出口因为错误率过高被暂时摘除，到 EjectedUntil 后恢复
+	Healthy      bool
+	EjectedUntil time.Time
+	Requests     int64
+	Failures     int64
+	// Sessions 分配到这个出口的会话数
+	Sessions int64
+}
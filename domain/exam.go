@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// ExamType 考试类型
+type ExamType int
+
+const (
+	FinalExam ExamType = iota
+	// MakeupExam 补考
+	MakeupExam
+	// RetakeExam 重修考试
+	RetakeExam
+)
+
+// Exam 一场考试的安排
+type Exam struct {
+	CourseId string
+	Name     string
+	// ExamName 教务系统里的考试名称，如 2023-2024学年第一学期期末考试
+	ExamName string
+	Type     ExamType
+	// Start、End 考试的开始和结束时间，还没有安排时间时为零值
+	Start  time.Time
+	End    time.Time
+	Campus string
+	Room   string
+	// Seat 座位号，还没有排座位时为空
+	Seat string
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// LoginChallenge CAS 要求输入验证码时返回给调用方，调用方带着 Handle 和验证码完成登录
+type LoginChallenge struct {
+	// Handle 不透明的登录句柄，只能使用一次
+	Handle string
+	// Image 验证码图片
+	Image     []byte
+	ImageType string
+	ExpireAt  time.Time
+}
+
+// SessionToken 服务端签发的会话令牌，调用方用它代替学号和密码访问查询接口
+type SessionToken struct {
+	Token     string
+	StudentId string
+	ExpireAt  time.Time
+}
+
+// LoginAttempt 一次 CAS 登录尝试的审计记录
+type LoginAttempt struct {
+	StudentId string
+	// Caller 发起登录的调用方服务
+	Caller string
+	// Outcome 成功为 success，失败为小写的错误原因，比如 invalid_sid_or_pwd
+	Outcome string
+	Latency time.Duration
+	Time    time.Time
+}
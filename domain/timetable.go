@@ -0,0 +1,49 @@
+package domain
+
+// WeekParity 单双周
+type WeekParity int
+
+const (
+	EveryWeek WeekParity = iota
+	OddWeeks
+	EvenWeeks
+)
+
+// WeekRange 上课的周次范围，比如 1-15 周（单）
+type WeekRange struct {
+	Start  int
+	End    int
+	Parity WeekParity
+}
+
+// Contains 第 week 周是否在范围内
+func (r WeekRange) Contains(week int) bool {
+	if week < r.Start || week > r.End {
+		return false
+	}
+	switch r.Parity {
+	case OddWeeks:
+		return week%2 == 1
+	case EvenWeeks:
+		return week%2 == 0
+	}
+	return true
+}
+
+// ClassSession 课表上的一次课，同一门课每周上几次就有几条
+type ClassSession struct {
+	CourseId string
+	Name     string
+	Teacher  string
+	Class    string // 教学班
+	// Weekday 星期几，1 是星期一，7 是星期日
+	Weekday     int
+	StartPeriod int
+	EndPeriod   int
+	Weeks       []WeekRange
+	// WeeksText 教务系统原始的周次描述，如 1-15周(单),2-8周(双)
+	WeeksText string
+	Campus    string
+	Building  string
+	Room      string
+}
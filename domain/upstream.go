@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+// UpstreamStatus 一个上游页面的可用性
+type UpstreamStatus struct {
+	Name string
+	URL  string
+	Up   bool
+	// Since 当前状态从什么时候开始，还没有探测过时为零值
+	Since     time.Time
+	LastCheck time.Time
+	// Latency 最近一次探测的耗时
+	Latency time.Duration
+	// Availability 保留的探测记录中成功的比例
+	Availability float64
+	// History 最近的探测记录，按时间倒序
+	History []ProbeResult
+}
+
+// ProbeResult 一次探测的结果
+type ProbeResult struct {
+	Time    time.Time
+	Up      bool
+	Latency time.Duration
+	// Error 失败原因，成功时为空
+	Error string
+}
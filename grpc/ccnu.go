@@ -7,15 +7,18 @@ import (
 	"github.com/asynccnu/be-ccnu/service"
 	"github.com/ecodeclub/ekit/slice"
 	"google.golang.org/grpc"
+	"strings"
+	"time"
 )
 
 type CCNUServiceServer struct {
 	ccnuv1.UnimplementedCCNUServiceServer
 	ccnu service.CCNUService
+	auth *CallerAuth
 }
 
-func NewCCNUServiceServer(ccnu service.CCNUService) *CCNUServiceServer {
-	return &CCNUServiceServer{ccnu: ccnu}
+func NewCCNUServiceServer(ccnu service.CCNUService, auth *CallerAuth) *CCNUServiceServer {
+	return &CCNUServiceServer{ccnu: ccnu, auth: auth}
 }
 
 func (s *CCNUServiceServer) Register(server grpc.ServiceRegistrar) {
@@ -23,15 +26,145 @@ func (s *CCNUServiceServer) Register(server grpc.ServiceRegistrar) {
 }
 
 func (s *CCNUServiceServer) Login(ctx context.Context, request *ccnuv1.LoginRequest) (*ccnuv1.LoginResponse, error) {
+	if request.GetIssueToken() {
+		// 令牌可以代替密码查询，只签发给受信任的调用方
+		if err := s.auth.requireTrusted(ctx); err != nil {
+			return &ccnuv1.LoginResponse{Success: false}, err
+		}
+		token, err := s.ccnu.IssueSessionToken(ctx, request.GetStudentId(), request.GetPassword())
+		if err != nil {
+			return &ccnuv1.LoginResponse{Success: false}, err
+		}
+		return &ccnuv1.LoginResponse{
+			Success:      true,
+			SessionToken: token.Token,
+			ExpireAt:     token.ExpireAt.Unix(),
+		}, nil
+	}
 	success, err := s.ccnu.Login(ctx, request.GetStudentId(), request.GetPassword())
 	return &ccnuv1.LoginResponse{Success: success}, err
 }
+
+func (s *CCNUServiceServer) Logout(ctx context.Context, request *ccnuv1.LogoutRequest) (*ccnuv1.LogoutResponse, error) {
+	err := s.ccnu.RevokeSessionToken(ctx, request.GetSessionToken())
+	return &ccnuv1.LogoutResponse{}, err
+}
+
+func (s *CCNUServiceServer) StartLogin(ctx context.Context, request *ccnuv1.StartLoginRequest) (*ccnuv1.StartLoginResponse, error) {
+	challenge, err := s.ccnu.StartLogin(ctx, request.GetStudentId(), request.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return &ccnuv1.StartLoginResponse{Success: true}, nil
+	}
+	return &ccnuv1.StartLoginResponse{
+		CaptchaRequired: true,
+		LoginHandle:     challenge.Handle,
+		CaptchaImage:    challenge.Image,
+		CaptchaMimeType: challenge.ImageType,
+		ExpireAt:        challenge.ExpireAt.Unix(),
+	}, nil
+}
+
+func (s *CCNUServiceServer) FinishLogin(ctx context.Context, request *ccnuv1.FinishLoginRequest) (*ccnuv1.FinishLoginResponse, error) {
+	challenge, err := s.ccnu.FinishLogin(ctx, request.GetLoginHandle(), request.GetCaptcha())
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return &ccnuv1.FinishLoginResponse{Success: true}, nil
+	}
+	return &ccnuv1.FinishLoginResponse{
+		CaptchaRequired: true,
+		LoginHandle:     challenge.Handle,
+		CaptchaImage:    challenge.Image,
+		CaptchaMimeType: challenge.ImageType,
+		ExpireAt:        challenge.ExpireAt.Unix(),
+	}, nil
+}
+
+func (s *CCNUServiceServer) SaveCredential(ctx context.Context, request *ccnuv1.SaveCredentialRequest) (*ccnuv1.SaveCredentialResponse, error) {
+	err := s.ccnu.SaveCredential(ctx, request.GetStudentId(), request.GetPassword())
+	return &ccnuv1.SaveCredentialResponse{}, err
+}
+
+func (s *CCNUServiceServer) DeleteCredential(ctx context.Context, request *ccnuv1.DeleteCredentialRequest) (*ccnuv1.DeleteCredentialResponse, error) {
+	if err := s.auth.requireTrusted(ctx); err != nil {
+		return nil, err
+	}
+	err := s.ccnu.DeleteCredential(ctx, request.GetStudentId())
+	return &ccnuv1.DeleteCredentialResponse{}, err
+}
+
+func (s *CCNUServiceServer) ReEncryptCredentials(ctx context.Context, request *ccnuv1.ReEncryptCredentialsRequest) (*ccnuv1.ReEncryptCredentialsResponse, error) {
+	if err := s.auth.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	cnt, err := s.ccnu.ReEncryptCredentials(ctx)
+	return &ccnuv1.ReEncryptCredentialsResponse{Count: int64(cnt)}, err
+}
+
+// GetCCNUCookie 返回的 CASTGC 可以登录学生的所有校内系统，只给受信任的调用方
 func (s *CCNUServiceServer) GetCCNUCookie(ctx context.Context, request *ccnuv1.GetCCNUCookieRequest) (*ccnuv1.GetCCNUCookieResponse, error) {
-	cookie, err := s.ccnu.GetCCNUCookie(ctx, request.GetStudentId(), request.GetPassword())
-	return &ccnuv1.GetCCNUCookieResponse{Cookie: cookie}, err
+	if err := s.auth.requireTrusted(ctx); err != nil {
+		return nil, err
+	}
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
+	systems, err := s.ccnu.GetCCNUCookie(ctx, request.GetStudentId(), request.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+	res := &ccnuv1.GetCCNUCookieResponse{
+		Systems: slice.Map(systems, func(idx int, src domain.SystemCookies) *ccnuv1.SystemCookies {
+			return &ccnuv1.SystemCookies{
+				System:  src.System,
+				Cookies: slice.Map(src.Cookies, convertToCookieV),
+			}
+		}),
+	}
+	// Cookie 字段保留给老的调用方，是教务系统 Cookie 拼成的请求头
+	if sys, ok := xkSystemCookies(systems); ok {
+		pairs := slice.Map(sys.Cookies, func(idx int, src domain.Cookie) string {
+			return src.Name + "=" + src.Value
+		})
+		res.Cookie = strings.Join(pairs, "; ")
+	}
+	return res, nil
+}
+
+// xkSystemCookies GetCCNUCookie 返回 CAS 和学生所在的一个教务系统（本科生是 jwglxt，研究生是 yjsxt），
+// 这里取出那个教务系统
+func xkSystemCookies(systems []domain.SystemCookies) (domain.SystemCookies, bool) {
+	for _, sys := range systems {
+		if sys.System != "cas" {
+			return sys, true
+		}
+	}
+	return domain.SystemCookies{}, false
+}
+
+func (s *CCNUServiceServer) GetServiceCookies(ctx context.Context, request *ccnuv1.GetServiceCookiesRequest) (*ccnuv1.GetServiceCookiesResponse, error) {
+	if err := s.auth.requireTrusted(ctx); err != nil {
+		return nil, err
+	}
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
+	systems, err := s.ccnu.GetServiceCookies(ctx, request.GetStudentId(), request.GetPassword(), request.GetServices())
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.GetServiceCookiesResponse{
+		Systems: slice.Map(systems, func(idx int, src domain.SystemCookies) *ccnuv1.SystemCookies {
+			return &ccnuv1.SystemCookies{
+				System:  src.System,
+				Cookies: slice.Map(src.Cookies, convertToCookieV),
+			}
+		}),
+	}, nil
 }
 
 func (s *CCNUServiceServer) CourseList(ctx context.Context, request *ccnuv1.CourseListRequest) (*ccnuv1.CourseListResponse, error) {
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
 	var courseVos []*ccnuv1.Course
 	// 利用成绩接口，或者老接口
 	if request.GetSource() == ccnuv1.Source_GradeApi {
@@ -61,8 +194,95 @@ func (s *CCNUServiceServer) CourseList(ctx context.Context, request *ccnuv1.Cour
 	}, nil
 }
 
+func (s *CCNUServiceServer) GetTimetable(ctx context.Context, request *ccnuv1.GetTimetableRequest) (*ccnuv1.GetTimetableResponse, error) {
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
+	sessions, err := s.ccnu.GetTimetable(ctx, request.GetStudentId(), request.GetPassword(), request.GetYear(), request.GetTerm())
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.GetTimetableResponse{
+		Sessions: slice.Map(sessions, convertToClassSessionV),
+	}, nil
+}
+
+func (s *CCNUServiceServer) ExportTimetable(ctx context.Context, request *ccnuv1.ExportTimetableRequest) (*ccnuv1.ExportTimetableResponse, error) {
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
+	data, err := s.ccnu.ExportTimetable(ctx, request.GetStudentId(), request.GetPassword(), request.GetYear(), request.GetTerm())
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.ExportTimetableResponse{
+		Calendar: data,
+		Filename: service.TimetableFilename(request.GetYear(), request.GetTerm()),
+	}, nil
+}
+
+// SearchFreeClassrooms 学号和密码可以不传，没有可借用的会话时才会用到
+func (s *CCNUServiceServer) SearchFreeClassrooms(ctx context.Context, request *ccnuv1.SearchFreeClassroomsRequest) (*ccnuv1.SearchFreeClassroomsResponse, error) {
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
+	rooms, err := s.ccnu.SearchFreeClassrooms(ctx, request.GetStudentId(), request.GetPassword(), domain.FreeClassroomQuery{
+		Campus:      request.GetCampus(),
+		Building:    request.GetBuilding(),
+		Week:        int(request.GetWeek()),
+		Weekday:     int(request.GetWeekday()),
+		StartPeriod: int(request.GetStartPeriod()),
+		EndPeriod:   int(request.GetEndPeriod()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.SearchFreeClassroomsResponse{
+		Classrooms: slice.Map(rooms, func(idx int, src domain.Classroom) *ccnuv1.Classroom {
+			return &ccnuv1.Classroom{
+				Id:       src.Id,
+				Name:     src.Name,
+				Campus:   src.Campus,
+				Building: src.Building,
+				Capacity: int32(src.Capacity),
+				Type:     src.Type,
+			}
+		}),
+	}, nil
+}
+
+func (s *CCNUServiceServer) GetCurrentTerm(ctx context.Context, request *ccnuv1.GetCurrentTermRequest) (*ccnuv1.GetCurrentTermResponse, error) {
+	t, err := s.ccnu.GetCurrentTerm(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.GetCurrentTermResponse{Term: convertToTermV(t)}, nil
+}
+
+// GetWeekOf date 的格式为 2006-01-02，为空时查询今天
+func (s *CCNUServiceServer) GetWeekOf(ctx context.Context, request *ccnuv1.GetWeekOfRequest) (*ccnuv1.GetWeekOfResponse, error) {
+	date := time.Now()
+	if request.GetDate() != "" {
+		var err error
+		date, err = time.ParseInLocation(time.DateOnly, request.GetDate(), time.Local)
+		if err != nil {
+			return nil, ccnuv1.ErrorInvalidDate("日期格式应为 2006-01-02: %s", request.GetDate())
+		}
+		// 只关心年月日，按中午算，避免服务器时区和学校不同时差一天
+		date = date.Add(time.Hour * 12)
+	}
+	day, err := s.ccnu.GetWeekOf(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.GetWeekOfResponse{
+		Date:        day.Date.Format(time.DateOnly),
+		Term:        convertToTermV(day.Term),
+		Week:        int32(day.Week),
+		Weekday:     int32(day.Weekday),
+		Holiday:     day.Holiday,
+		HolidayName: day.HolidayName,
+		Makeup:      day.Makeup,
+	}, nil
+}
+
 // todo: 修改Grade服务，废弃该方法，使用GetGrades代替
 func (s *CCNUServiceServer) GetAllGrades(ctx context.Context, request *ccnuv1.GetAllGradesRequest) (*ccnuv1.GetAllGradesResponse, error) {
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
 	grades, err := s.ccnu.GetDetailOfGradeList(ctx, request.GetStudentId(), request.GetPassword(), "", "")
 	return &ccnuv1.GetAllGradesResponse{
 		Grades: slice.Map(grades, func(idx int, src domain.Grade) *ccnuv1.Grade {
@@ -72,6 +292,7 @@ func (s *CCNUServiceServer) GetAllGrades(ctx context.Context, request *ccnuv1.Ge
 }
 
 func (s *CCNUServiceServer) GetGrades(ctx context.Context, request *ccnuv1.GetGradesRequest) (*ccnuv1.GetGradesResponse, error) {
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
 	grades, err := s.ccnu.GetDetailOfGradeList(ctx, request.GetStudentId(), request.GetPassword(), request.GetYear(), request.GetYear())
 	return &ccnuv1.GetGradesResponse{
 		Grades: slice.Map(grades, func(idx int, src domain.Grade) *ccnuv1.Grade {
@@ -80,6 +301,96 @@ func (s *CCNUServiceServer) GetGrades(ctx context.Context, request *ccnuv1.GetGr
 	}, err
 }
 
+func (s *CCNUServiceServer) GetExams(ctx context.Context, request *ccnuv1.GetExamsRequest) (*ccnuv1.GetExamsResponse, error) {
+	ctx = service.WithSessionToken(ctx, request.GetSessionToken())
+	exams, err := s.ccnu.GetExams(ctx, request.GetStudentId(), request.GetPassword(), request.GetYear(), request.GetTerm())
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.GetExamsResponse{
+		Exams: slice.Map(exams, convertToExamV),
+	}, nil
+}
+
+// ListLoginAttempts 审计记录能看出每个学号什么时候、通过哪个调用方登录过，只允许 Admins 里的调用方查询
+func (s *CCNUServiceServer) ListLoginAttempts(ctx context.Context, request *ccnuv1.ListLoginAttemptsRequest) (*ccnuv1.ListLoginAttemptsResponse, error) {
+	if err := s.auth.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	end := time.Now()
+	if request.GetEndTime() > 0 {
+		end = time.Unix(request.GetEndTime(), 0)
+	}
+	limit := int(request.GetLimit())
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	attempts, err := s.ccnu.ListLoginAttempts(ctx, request.GetStudentId(), time.Unix(request.GetStartTime(), 0), end,
+		int(request.GetOffset()), limit)
+	if err != nil {
+		return nil, err
+	}
+	return &ccnuv1.ListLoginAttemptsResponse{
+		Attempts: slice.Map(attempts, func(idx int, src domain.LoginAttempt) *ccnuv1.LoginAttempt {
+			return &ccnuv1.LoginAttempt{
+				StudentId: src.StudentId,
+				Caller:    src.Caller,
+				Outcome:   src.Outcome,
+				LatencyMs: src.Latency.Milliseconds(),
+				Time:      src.Time.Unix(),
+			}
+		}),
+	}, nil
+}
+
+func (s *CCNUServiceServer) ListEgressStats(ctx context.Context, request *ccnuv1.ListEgressStatsRequest) (*ccnuv1.ListEgressStatsResponse, error) {
+	stats := s.ccnu.ListEgressStats(ctx)
+	return &ccnuv1.ListEgressStatsResponse{
+		Egresses: slice.Map(stats, func(idx int, src domain.EgressStats) *ccnuv1.EgressStats {
+			res := &ccnuv1.EgressStats{
+				Name:     src.Name,
+				Healthy:  src.Healthy,
+				Requests: src.Requests,
+				Failures: src.Failures,
+				Sessions: src.Sessions,
+			}
+			if !src.Healthy {
+				res.EjectedUntil = src.EjectedUntil.Unix()
+			}
+			return res
+		}),
+	}, nil
+}
+
+func (s *CCNUServiceServer) GetUpstreamStatus(ctx context.Context, request *ccnuv1.GetUpstreamStatusRequest) (*ccnuv1.GetUpstreamStatusResponse, error) {
+	statuses := s.ccnu.GetUpstreamStatus(ctx)
+	return &ccnuv1.GetUpstreamStatusResponse{
+		Upstreams: slice.Map(statuses, func(idx int, src domain.UpstreamStatus) *ccnuv1.UpstreamStatus {
+			res := &ccnuv1.UpstreamStatus{
+				Name:         src.Name,
+				Url:          src.URL,
+				Up:           src.Up,
+				LatencyMs:    src.Latency.Milliseconds(),
+				Availability: src.Availability,
+				History: slice.Map(src.History, func(idx int, src domain.ProbeResult) *ccnuv1.ProbeResult {
+					return &ccnuv1.ProbeResult{
+						Time:      src.Time.Unix(),
+						Up:        src.Up,
+						LatencyMs: src.Latency.Milliseconds(),
+						Error:     src.Error,
+					}
+				}),
+			}
+			// 还没有探测过时不返回时间
+			if !src.LastCheck.IsZero() {
+				res.Since = src.Since.Unix()
+				res.LastCheck = src.LastCheck.Unix()
+			}
+			return res
+		}),
+	}, nil
+}
+
 func convertToCourseV(c domain.Course) *ccnuv1.Course {
 	return &ccnuv1.Course{
 		CourseCode: c.CourseId,
@@ -94,6 +405,70 @@ func convertToCourseV(c domain.Course) *ccnuv1.Course {
 	}
 }
 
+func convertToClassSessionV(idx int, c domain.ClassSession) *ccnuv1.ClassSession {
+	return &ccnuv1.ClassSession{
+		CourseCode:  c.CourseId,
+		Name:        c.Name,
+		Teacher:     c.Teacher,
+		Class:       c.Class,
+		Weekday:     int32(c.Weekday),
+		StartPeriod: int32(c.StartPeriod),
+		EndPeriod:   int32(c.EndPeriod),
+		Weeks: slice.Map(c.Weeks, func(idx int, src domain.WeekRange) *ccnuv1.WeekRange {
+			return &ccnuv1.WeekRange{
+				Start:  int32(src.Start),
+				End:    int32(src.End),
+				Parity: ccnuv1.WeekParity(src.Parity),
+			}
+		}),
+		WeeksText: c.WeeksText,
+		Campus:    c.Campus,
+		Building:  c.Building,
+		Room:      c.Room,
+	}
+}
+
+func convertToExamV(idx int, e domain.Exam) *ccnuv1.Exam {
+	res := &ccnuv1.Exam{
+		CourseCode: e.CourseId,
+		Name:       e.Name,
+		ExamName:   e.ExamName,
+		Type:       ccnuv1.ExamType(e.Type),
+		Campus:     e.Campus,
+		Room:       e.Room,
+		Seat:       e.Seat,
+	}
+	// 还没有安排时间时不返回时间
+	if !e.Start.IsZero() {
+		res.StartTime = e.Start.Unix()
+		res.EndTime = e.End.Unix()
+	}
+	return res
+}
+
+func convertToTermV(t domain.Term) *ccnuv1.Term {
+	return &ccnuv1.Term{
+		Year:  t.Year,
+		Term:  t.Term,
+		Start: t.Start.Format(time.DateOnly),
+		End:   t.End().AddDate(0, 0, -1).Format(time.DateOnly),
+		Weeks: int32(t.Weeks),
+	}
+}
+
+func convertToCookieV(idx int, c domain.Cookie) *ccnuv1.Cookie {
+	cookie := &ccnuv1.Cookie{
+		Name:   c.Name,
+		Value:  c.Value,
+		Domain: c.Domain,
+		Path:   c.Path,
+	}
+	if !c.ExpireAt.IsZero() {
+		cookie.ExpireAt = c.ExpireAt.Unix()
+	}
+	return cookie
+}
+
 func convertToGradeV(g domain.Grade) *ccnuv1.Grade {
 	return &ccnuv1.Grade{
 		CourseCode:    g.Course.CourseId,
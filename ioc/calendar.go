@@ -0,0 +1,49 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/spf13/viper"
+)
+
+// InitAcademicCalendar 默认的作息时间以学校公布的为准，每学期的校历需要在配置里补上
+func InitAcademicCalendar() *service.AcademicCalendar {
+	cfg, err := loadCalendarConfig()
+	if err != nil {
+		panic(err)
+	}
+	cal, err := service.NewAcademicCalendar(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cal
+}
+
+// ReloadAcademicCalendar 配置文件修改后重新加载校历，新的配置有误时继续使用原来的校历
+func ReloadAcademicCalendar(cal *service.AcademicCalendar) error {
+	cfg, err := loadCalendarConfig()
+	if err != nil {
+		return err
+	}
+	return cal.Reload(cfg)
+}
+
+func loadCalendarConfig() (service.CalendarConfig, error) {
+	cfg := service.CalendarConfig{
+		Periods: []service.PeriodTime{
+			{Start: "08:00", End: "08:45"},
+			{Start: "08:55", End: "09:40"},
+			{Start: "10:10", End: "10:55"},
+			{Start: "11:05", End: "11:50"},
+			{Start: "14:00", End: "14:45"},
+			{Start: "14:55", End: "15:40"},
+			{Start: "16:10", End: "16:55"},
+			{Start: "17:05", End: "17:50"},
+			{Start: "18:40", End: "19:25"},
+			{Start: "19:35", End: "20:20"},
+			{Start: "20:30", End: "21:15"},
+			{Start: "21:25", End: "22:10"},
+		},
+	}
+	err := viper.UnmarshalKey("calendar", &cfg)
+	return cfg, err
+}
@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/repository"
+	"github.com/spf13/viper"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// InitDB 没有配置 db.dsn 时返回 nil，此时各个存储都使用内存实现
+func InitDB() *gorm.DB {
+	type Config struct {
+		DSN string `mapstructure:"dsn"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("db", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.DSN == "" {
+		return nil
+	}
+	db, err := gorm.Open(mysql.Open(cfg.DSN))
+	if err != nil {
+		panic(err)
+	}
+	err = repository.InitTables(db)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
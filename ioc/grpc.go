@@ -6,12 +6,23 @@ import (
 	"github.com/asynccnu/be-ccnu/pkg/logger"
 	"github.com/go-kratos/kratos/v2/middleware/recovery"
 	kgrpc "github.com/go-kratos/kratos/v2/transport/grpc"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
 	"github.com/spf13/viper"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"time"
 )
 
-func InitGRPCxKratosServer(ccnuServer *grpc.CCNUServiceServer, ecli *clientv3.Client, l logger.Logger) grpcx.Server {
+// InitCallerAuth 没有配置时所有调用方都是匿名的，获取 Cookie、签发令牌、管理保存的密码等接口都不能调用
+func InitCallerAuth() *grpc.CallerAuth {
+	var cfg grpc.CallerAuthConfig
+	err := viper.UnmarshalKey("callerAuth", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return grpc.NewCallerAuth(cfg)
+}
+
+func InitGRPCxKratosServer(ccnuServer *grpc.CCNUServiceServer, auth *grpc.CallerAuth, httpServer *khttp.Server, ecli *clientv3.Client, l logger.Logger) grpcx.Server {
 	type Config struct {
 		Name    string `yaml:"name"`
 		Weight  int    `yaml:"weight"`
@@ -25,13 +36,14 @@ func InitGRPCxKratosServer(ccnuServer *grpc.CCNUServiceServer, ecli *clientv3.Cl
 	}
 	server := kgrpc.NewServer(
 		kgrpc.Address(cfg.Addr),
-		kgrpc.Middleware(recovery.Recovery()),
+		kgrpc.Middleware(recovery.Recovery(), auth.Middleware()),
 		kgrpc.Timeout(10*time.Second), // TODO
 	)
 
 	ccnuServer.Register(server)
 	return &grpcx.KratosServer{
 		Server:     server,
+		HTTP:       httpServer,
 		Name:       cfg.Name,
 		Weight:     cfg.Weight,
 		EtcdTTL:    time.Second * time.Duration(cfg.EtcdTTL),
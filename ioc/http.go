@@ -0,0 +1,22 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/web"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// InitHTTPServer 没有配置 http.server.addr 时不提供 HTTP 接口
+func InitHTTPServer(timetable *web.TimetableHandler) *khttp.Server {
+	addr := viper.GetString("http.server.addr")
+	if addr == "" {
+		return nil
+	}
+	server := khttp.NewServer(
+		khttp.Address(addr),
+		khttp.Timeout(10*time.Second),
+	)
+	timetable.Register(server)
+	return server
+}
@@ -0,0 +1,23 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/spf13/viper"
+	"time"
+)
+
+func InitKeepAliveConfig() service.KeepAliveConfig {
+	// 默认值：每 10 分钟刷新一轮最近 2 小时用过的会话，每轮最多 2000 个，同时刷新 8 个
+	cfg := service.KeepAliveConfig{
+		Interval:     time.Minute * 10,
+		ActiveWithin: time.Hour * 2,
+		MaxSessions:  2000,
+		Concurrency:  8,
+		Jitter:       time.Second * 30,
+	}
+	err := viper.UnmarshalKey("keepAlive", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
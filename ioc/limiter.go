@@ -0,0 +1,28 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/spf13/viper"
+	"time"
+)
+
+func InitLoginLimiter() *service.LoginLimiter {
+	// 默认值：全局每秒 20 次；单个学号最多连续 3 次，之后 5 秒一次；连续 3 次密码错误暂停 15 分钟
+	cfg := service.LoginLimitConfig{
+		GlobalRate:      20,
+		GlobalBurst:     40,
+		StudentRate:     0.2,
+		StudentBurst:    3,
+		MaxBadPasswords: 3,
+		Lockout:         time.Minute * 15,
+	}
+	err := viper.UnmarshalKey("loginLimit", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	l, err := service.NewLoginLimiter(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
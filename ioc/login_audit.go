@@ -0,0 +1,30 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/repository"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"time"
+)
+
+func InitLoginAuditor(db *gorm.DB, l logger.Logger) *service.LoginAuditor {
+	// 默认保留 90 天，每小时清理一次，最多 1024 条记录等待写入
+	cfg := service.LoginAuditConfig{
+		Retention:     time.Hour * 24 * 90,
+		PruneInterval: time.Hour,
+		QueueSize:     1024,
+	}
+	err := viper.UnmarshalKey("loginAudit", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	var repo repository.LoginAttemptRepository
+	if db != nil {
+		repo = repository.NewGORMLoginAttemptRepository(db)
+	} else {
+		repo = repository.NewMemoryLoginAttemptRepository()
+	}
+	return service.NewLoginAuditor(repo, cfg, l)
+}
@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/pkg/metricsx"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/spf13/viper"
+	"time"
+)
+
+func InitUpstreamProber(upstream service.UpstreamConfig, egress *httpx.EgressPool, resilience *httpx.Resilience,
+	l logger.Logger) *service.UpstreamProber {
+	// 默认每 30 秒探测一次，保留最近一小时的记录，连续 3 轮失败才熔断
+	cfg := service.ProbeConfig{
+		Interval:         time.Second * 30,
+		Timeout:          time.Second * 5,
+		History:          120,
+		FailureThreshold: 3,
+	}
+	err := viper.UnmarshalKey("probe", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewUpstreamProber(upstream, egress, resilience, cfg, l)
+}
+
+// InitMetricsServer 没有配置 metrics.addr 时不暴露指标
+func InitMetricsServer(prober *service.UpstreamProber, egress *httpx.EgressPool, l logger.Logger) *metricsx.Server {
+	addr := viper.GetString("metrics.addr")
+	return metricsx.NewServer(addr, l, prober, egress)
+}
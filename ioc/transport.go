@@ -0,0 +1,56 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// InitEgressPool 请求上游的出口池，出口之间不共享连接，每个会话仍然有自己的 Cookie Jar
+func InitEgressPool(l logger.Logger) *httpx.EgressPool {
+	transport := httpx.TransportConfig{
+		MaxIdleConns:          200,
+		MaxIdleConnsPerHost:   50,
+		MaxConnsPerHost:       100,
+		IdleConnTimeout:       time.Second * 90,
+		DialTimeout:           time.Second * 3,
+		TLSHandshakeTimeout:   time.Second * 3,
+		ResponseHeaderTimeout: time.Second * 5,
+	}
+	err := viper.UnmarshalKey("transport", &transport)
+	if err != nil {
+		panic(err)
+	}
+	cfg := httpx.EgressPoolConfig{
+		Window:        time.Minute,
+		MinRequests:   20,
+		MaxErrorRate:  0.5,
+		EjectDuration: time.Minute * 5,
+	}
+	err = viper.UnmarshalKey("egress", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	p, err := httpx.NewEgressPool(transport, cfg, l)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// InitResilience 请求上游时的重试和熔断
+func InitResilience() *httpx.Resilience {
+	cfg := httpx.ResilienceConfig{
+		MaxRetries:       2,
+		BaseBackoff:      time.Millisecond * 100,
+		MaxBackoff:       time.Second,
+		FailureThreshold: 5,
+		OpenDuration:     time.Second * 30,
+	}
+	err := viper.UnmarshalKey("resilience", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return httpx.NewResilience(cfg)
+}
@@ -0,0 +1,16 @@
+package ioc
+
+import (
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/spf13/viper"
+)
+
+// InitUpstreamConfig 配置里没有写的地址使用学校线上环境的默认值
+func InitUpstreamConfig() service.UpstreamConfig {
+	cfg := service.DefaultUpstreamConfig()
+	err := viper.UnmarshalKey("upstream", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
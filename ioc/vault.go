@@ -0,0 +1,49 @@
+package ioc
+
+import (
+	"encoding/base64"
+	"github.com/asynccnu/be-ccnu/pkg/cryptox"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/repository"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// InitCredentialVault 没有配置加密密钥时返回 nil，即不启用密码保存
+func InitCredentialVault(db *gorm.DB, l logger.Logger) *service.CredentialVault {
+	type Config struct {
+		// PrimaryKey 用于加密新数据的密钥 id，轮换密钥时先加入新密钥并改成主密钥，再调用重新加密
+		PrimaryKey string `mapstructure:"primaryKey"`
+		// Keys 密钥 id 到 base64 编码的 AES 密钥，注意 viper 会把 id 转成小写
+		Keys map[string]string `mapstructure:"keys"`
+	}
+	var cfg Config
+	err := viper.UnmarshalKey("vault", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if len(cfg.Keys) == 0 {
+		return nil
+	}
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for id, k := range cfg.Keys {
+		key, er := base64.StdEncoding.DecodeString(k)
+		if er != nil {
+			panic(er)
+		}
+		keys[id] = key
+	}
+	keyring, err := cryptox.NewKeyring(strings.ToLower(cfg.PrimaryKey), keys)
+	if err != nil {
+		panic(err)
+	}
+	var repo repository.CredentialRepository
+	if db != nil {
+		repo = repository.NewGORMCredentialRepository(db)
+	} else {
+		repo = repository.NewMemoryCredentialRepository()
+	}
+	return service.NewCredentialVault(repo, keyring, l)
+}
@@ -1,14 +1,28 @@
 package main
 
 import (
+	"github.com/asynccnu/be-ccnu/ioc"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 func main() {
 	initViper()
-	server := InitGRPCServer()
-	err := server.Serve()
+	app := InitApp()
+	watchConfig(app.l, map[string]func() error{
+		"calendar": func() error { return ioc.ReloadAcademicCalendar(app.calendar) },
+	})
+	app.keeper.Start()
+	defer app.keeper.Stop()
+	app.auditor.Start()
+	defer app.auditor.Stop()
+	app.prober.Start()
+	defer app.prober.Stop()
+	app.metrics.Start()
+	defer app.metrics.Stop()
+	err := app.server.Serve()
 	if err != nil {
 		panic(err)
 	}
@@ -25,3 +39,17 @@ func initViper() {
 		panic(err)
 	}
 }
+
+// watchConfig 配置文件修改后依次通知支持热更新的组件。viper 只保留一个回调，所有订阅都在这里分发
+func watchConfig(l logger.Logger, reloaders map[string]func() error) {
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		for name, reload := range reloaders {
+			if err := reload(); err != nil {
+				l.Error("重新加载配置失败", logger.String("component", name), logger.Error(err))
+				continue
+			}
+			l.Info("重新加载了配置", logger.String("component", name), logger.String("file", e.Name))
+		}
+	})
+	viper.WatchConfig()
+}
@@ -0,0 +1,68 @@
+package cryptox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownKey          = errors.New("未知的密钥")
+	ErrMalformedCiphertext = errors.New("密文格式错误")
+)
+
+// Keyring 一组 AES-GCM 密钥，新数据总是用主密钥加密，旧密钥只用于解密，方便做密钥轮换
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring keys 是密钥 id 到密钥的映射，密钥长度必须是 16、24 或 32 字节
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("主密钥 %s 不存在", primary)
+	}
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %s 不合法: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads[id] = aead
+	}
+	return &Keyring{primary: primary, aeads: aeads}, nil
+}
+
+// Primary 主密钥的 id
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt 使用主密钥加密，返回密钥 id 和 nonce+密文。aad 会参与认证但不会被加密，用于把密文和它的归属绑定起来
+func (k *Keyring) Encrypt(plaintext, aad []byte) (string, []byte, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+	return k.primary, aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Decrypt 使用 keyId 对应的密钥解密
+func (k *Keyring) Decrypt(keyId string, ciphertext, aad []byte) ([]byte, error) {
+	aead, ok := k.aeads[keyId]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrMalformedCiphertext
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, aad)
+}
@@ -0,0 +1,75 @@
+package cryptox
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef0123456789abcdef")
+	newKey = []byte("fedcba9876543210")
+)
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k, err := NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyId, ciphertext, err := k.Encrypt([]byte("secret"), []byte("2023214001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyId != "k1" || bytes.Contains(ciphertext, []byte("secret")) {
+		t.Fatalf("密文有误: %s %q", keyId, ciphertext)
+	}
+	plaintext, err := k.Decrypt(keyId, ciphertext, []byte("2023214001"))
+	if err != nil || string(plaintext) != "secret" {
+		t.Fatalf("解密结果有误: %q, %v", plaintext, err)
+	}
+	// 附加数据不同，说明密文被挪到了别的学号下面
+	if _, err = k.Decrypt(keyId, ciphertext, []byte("2023214002")); err == nil {
+		t.Fatal("附加数据不一致时应该解密失败")
+	}
+	if _, err = k.Decrypt(keyId, ciphertext[:5], []byte("2023214001")); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatalf("密文太短时应该返回 ErrMalformedCiphertext: %v", err)
+	}
+	if _, err = k.Decrypt("k0", ciphertext, []byte("2023214001")); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("未知的密钥应该返回 ErrUnknownKey: %v", err)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	before, err := NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ciphertext, err := before.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 轮换后新数据用 k2 加密，k1 加密的旧数据仍然能解开
+	after, err := NewKeyring("k2", map[string][]byte{"k1": oldKey, "k2": newKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Primary() != "k2" {
+		t.Fatalf("主密钥应该是 k2: %s", after.Primary())
+	}
+	if plaintext, err := after.Decrypt("k1", ciphertext, nil); err != nil || string(plaintext) != "secret" {
+		t.Fatalf("轮换后应该能解开旧数据: %q, %v", plaintext, err)
+	}
+	keyId, _, err := after.Encrypt([]byte("secret"), nil)
+	if err != nil || keyId != "k2" {
+		t.Fatalf("轮换后应该用新的主密钥加密: %s, %v", keyId, err)
+	}
+}
+
+func TestNewKeyringValidates(t *testing.T) {
+	if _, err := NewKeyring("k2", map[string][]byte{"k1": oldKey}); err == nil {
+		t.Error("主密钥不存在时应该报错")
+	}
+	if _, err := NewKeyring("k1", map[string][]byte{"k1": []byte("short")}); err == nil {
+		t.Error("密钥长度不对时应该报错")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"github.com/asynccnu/be-ccnu/pkg/logger"
 	etcd "github.com/go-kratos/kratos/contrib/registry/etcd/v2"
 	"github.com/go-kratos/kratos/v2"
+	"github.com/go-kratos/kratos/v2/transport"
 	"github.com/go-kratos/kratos/v2/transport/grpc"
+	"github.com/go-kratos/kratos/v2/transport/http"
 	etcdv3 "go.etcd.io/etcd/client/v3"
 	"strconv"
 	"time"
@@ -12,6 +14,8 @@ import (
 
 type KratosServer struct {
 	*grpc.Server
+	// HTTP 和 gRPC 一起启停的 HTTP 服务，为 nil 时不启动
+	HTTP       *http.Server
 	Name       string
 	Weight     int
 	EtcdTTL    time.Duration
@@ -23,14 +27,16 @@ type KratosServer struct {
 // Serve 启动服务器并且阻塞
 func (s *KratosServer) Serve() error {
 	r := etcd.New(s.EtcdClient, etcd.RegisterTTL(s.EtcdTTL))
+	servers := []transport.Server{s.Server}
+	if s.HTTP != nil {
+		servers = append(servers, s.HTTP)
+	}
 	app := kratos.New(
 		kratos.Metadata(map[string]string{
 			"weight": strconv.Itoa(s.Weight),
 		}),
 		kratos.Name(s.Name),
-		kratos.Server(
-			s.Server,
-		),
+		kratos.Server(servers...),
 		kratos.Registrar(r),
 	)
 	s.stop = app.Stop
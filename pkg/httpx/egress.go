@@ -0,0 +1,260 @@
+package httpx
+
+import (
+	"fmt"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/pkg/metricsx"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// EgressConfig 一个出口，Proxy 和 LocalAddr 二选一，都为空时直连
+type EgressConfig struct {
+	// Name 出口名，用于监控，为空时使用 Proxy 或 LocalAddr
+	Name      string
+	Proxy     string
+	LocalAddr string
+}
+
+// EgressPoolConfig 出口池配置
+type EgressPoolConfig struct {
+	Egresses []EgressConfig
+	// Window 统计错误率的时间窗口
+	Window time.Duration
+	// MinRequests 窗口内请求数达到这个值才计算错误率，避免个别失败就摘除出口
+	MinRequests int
+	// MaxErrorRate 窗口内错误率达到这个值时摘除出口
+	MaxErrorRate float64
+	// EjectDuration 出口被摘除多久后重新参与分配
+	EjectDuration time.Duration
+}
+
+// EgressStats 出口的统计数据，Requests、Failures、Sessions 是启动以来的累计值
+type EgressStats struct {
+	Name         string
+	Healthy      bool
+	EjectedUntil time.Time
+	Requests     int64
+	Failures     int64
+	Sessions     int64
+}
+
+// EgressPool 把出站流量分散到多个出口上。
+// 每个会话在创建时通过 Pick 选定一个出口，之后一直使用它；错误率过高的出口会被暂时摘除
+type EgressPool struct {
+	cfg      EgressPoolConfig
+	egresses []*Egress
+	l        logger.Logger
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewEgressPool 为每个出口创建一个 Transport，没有配置出口时只有一个按 base 直连的出口
+func NewEgressPool(base TransportConfig, cfg EgressPoolConfig, l logger.Logger) (*EgressPool, error) {
+	egresses := cfg.Egresses
+	if len(egresses) == 0 {
+		egresses = []EgressConfig{{Proxy: base.Proxy, LocalAddr: base.LocalAddr}}
+	}
+	p := &EgressPool{
+		cfg:      cfg,
+		egresses: make([]*Egress, 0, len(egresses)),
+		l:        l,
+	}
+	names := make(map[string]struct{}, len(egresses))
+	for _, ec := range egresses {
+		tc := base
+		tc.Proxy, tc.LocalAddr = ec.Proxy, ec.LocalAddr
+		t, err := NewTransport(tc)
+		if err != nil {
+			return nil, err
+		}
+		name := egressName(ec)
+		if _, ok := names[name]; ok {
+			return nil, fmt.Errorf("出口重复: %s", name)
+		}
+		names[name] = struct{}{}
+		p.egresses = append(p.egresses, &Egress{
+			name: name,
+			rt:   t,
+			pool: p,
+		})
+	}
+	return p, nil
+}
+
+func egressName(ec EgressConfig) string {
+	switch {
+	case ec.Name != "":
+		return ec.Name
+	case ec.Proxy != "":
+		// 去掉代理地址里的用户名密码
+		u, err := ParseProxy(ec.Proxy)
+		if err == nil {
+			u.User = nil
+			return u.String()
+		}
+		return ec.Proxy
+	case ec.LocalAddr != "":
+		return ec.LocalAddr
+	default:
+		return "direct"
+	}
+}
+
+// Pick 给新会话选一个出口，计入出口的会话数
+func (p *EgressPool) Pick() *Egress {
+	res := p.Peek()
+	res.mu.Lock()
+	res.sessions++
+	res.mu.Unlock()
+	return res
+}
+
+// Peek 轮询选出一个健康的出口，所有出口都被摘除时选最早恢复的那个。
+// 不计入会话数，用于探测这类不属于会话的请求
+func (p *EgressPool) Peek() *Egress {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var res *Egress
+	for i := 0; i < len(p.egresses); i++ {
+		e := p.egresses[(p.next+i)%len(p.egresses)]
+		if e.healthy(now) {
+			res = e
+			p.next = (p.next + i + 1) % len(p.egresses)
+			break
+		}
+	}
+	if res == nil {
+		res = p.egresses[0]
+		for _, e := range p.egresses[1:] {
+			if e.ejectedBefore(res) {
+				res = e
+			}
+		}
+	}
+	return res
+}
+
+func (p *EgressPool) Stats() []EgressStats {
+	now := time.Now()
+	res := make([]EgressStats, 0, len(p.egresses))
+	for _, e := range p.egresses {
+		e.mu.Lock()
+		res = append(res, EgressStats{
+			Name:         e.name,
+			Healthy:      !now.Before(e.ejectedUntil),
+			EjectedUntil: e.ejectedUntil,
+			Requests:     e.requests,
+			Failures:     e.failures,
+			Sessions:     e.sessions,
+		})
+		e.mu.Unlock()
+	}
+	return res
+}
+
+// Egress 一个出口，实现了 http.RoundTripper，会统计经过它的请求
+type Egress struct {
+	name string
+	rt   http.RoundTripper
+	pool *EgressPool
+
+	mu           sync.Mutex
+	requests     int64
+	failures     int64
+	sessions     int64
+	windowStart  time.Time
+	windowReqs   int
+	windowFails  int
+	ejectedUntil time.Time
+}
+
+func (e *Egress) Name() string {
+	return e.name
+}
+
+func (e *Egress) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := e.rt.RoundTrip(req)
+	// 调用方自己取消的请求和出口无关，不计入统计
+	if err != nil && req.Context().Err() != nil {
+		return resp, err
+	}
+	e.observe(err != nil || isThrottled(resp))
+	return resp, err
+}
+
+// isThrottled 上游限流或者网关出错，通常说明这个出口被盯上了
+func isThrottled(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+func (e *Egress) observe(failed bool) {
+	cfg := e.pool.cfg
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	if failed {
+		e.failures++
+	}
+	if now.Sub(e.windowStart) >= cfg.Window {
+		e.windowStart, e.windowReqs, e.windowFails = now, 0, 0
+	}
+	e.windowReqs++
+	if failed {
+		e.windowFails++
+	}
+	if cfg.MaxErrorRate <= 0 || e.windowReqs < cfg.MinRequests || !now.After(e.ejectedUntil) {
+		return
+	}
+	rate := float64(e.windowFails) / float64(e.windowReqs)
+	if rate < cfg.MaxErrorRate {
+		return
+	}
+	e.ejectedUntil = now.Add(cfg.EjectDuration)
+	e.windowStart, e.windowReqs, e.windowFails = now, 0, 0
+	e.pool.l.Warn("出口错误率过高，暂时摘除", logger.String("egress", e.name),
+		logger.Any("errorRate", rate), logger.Any("until", e.ejectedUntil))
+}
+
+func (e *Egress) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.ejectedUntil)
+}
+
+func (e *Egress) ejectedBefore(other *Egress) bool {
+	e.mu.Lock()
+	until := e.ejectedUntil
+	e.mu.Unlock()
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	return until.Before(other.ejectedUntil)
+}
+
+// Collect 输出各个出口的请求数和健康状态
+func (p *EgressPool) Collect(w *metricsx.Writer) {
+	stats := p.Stats()
+	var requests, failures, sessions, healthy []metricsx.Sample
+	for _, s := range stats {
+		labels := map[string]string{"egress": s.Name}
+		requests = append(requests, metricsx.Sample{Labels: labels, Value: float64(s.Requests)})
+		failures = append(failures, metricsx.Sample{Labels: labels, Value: float64(s.Failures)})
+		sessions = append(sessions, metricsx.Sample{Labels: labels, Value: float64(s.Sessions)})
+		healthy = append(healthy, metricsx.Sample{Labels: labels, Value: boolValue(s.Healthy)})
+	}
+	w.Counter("ccnu_egress_requests_total", "经过出口的上游请求数", requests...)
+	w.Counter("ccnu_egress_failures_total", "经过出口失败或被限流的上游请求数", failures...)
+	w.Counter("ccnu_egress_sessions_total", "分配到出口的会话数", sessions...)
+	w.Gauge("ccnu_egress_healthy", "出口是否健康，被摘除时为 0", healthy...)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package httpx
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newTestPool 两个出口 a、b，各自的请求结果由 status 决定
+func newTestPool(t *testing.T, status map[string]int) *EgressPool {
+	t.Helper()
+	p, err := NewEgressPool(TransportConfig{}, EgressPoolConfig{
+		Egresses:      []EgressConfig{{Name: "a"}, {Name: "b"}},
+		Window:        time.Minute,
+		MinRequests:   4,
+		MaxErrorRate:  0.5,
+		EjectDuration: time.Hour,
+	}, logger.NewNopLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range p.egresses {
+		name := e.name
+		e.rt = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if err := req.Context().Err(); err != nil {
+				return nil, err
+			}
+			return respond(status[name]), nil
+		})
+	}
+	return p
+}
+
+func send(t *testing.T, e *Egress, ctx context.Context, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if resp, err := get(t, e, ctx); err == nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestEgressEjection(t *testing.T) {
+	p := newTestPool(t, map[string]int{"a": http.StatusTooManyRequests, "b": http.StatusOK})
+	a, b := p.Pick(), p.Pick()
+	if a.Name() != "a" || b.Name() != "b" {
+		t.Fatalf("应该轮流分配出口: %s, %s", a.Name(), b.Name())
+	}
+
+	// 请求数不够时不计算错误率
+	send(t, a, context.Background(), 3)
+	if !a.healthy(time.Now()) {
+		t.Fatal("请求数没有达到 MinRequests，不应该摘除")
+	}
+	// 调用方取消的请求不计入
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	send(t, a, ctx, 5)
+	if !a.healthy(time.Now()) {
+		t.Fatal("调用方取消的请求不应该计入错误率")
+	}
+
+	send(t, a, context.Background(), 1)
+	if a.healthy(time.Now()) {
+		t.Fatal("错误率过高的出口应该被摘除")
+	}
+	for i := 0; i < 3; i++ {
+		if e := p.Pick(); e != b {
+			t.Fatalf("被摘除的出口不应该再分配: %s", e.Name())
+		}
+	}
+
+	stats := p.Stats()
+	if stats[0].Healthy || stats[0].Requests != 4 || stats[0].Failures != 4 || stats[0].Sessions != 1 {
+		t.Fatalf("a 的统计有误: %+v", stats[0])
+	}
+	if !stats[1].Healthy || stats[1].Sessions != 4 {
+		t.Fatalf("b 的统计有误: %+v", stats[1])
+	}
+}
+
+func TestEgressAllEjected(t *testing.T) {
+	p := newTestPool(t, map[string]int{"a": http.StatusBadGateway, "b": http.StatusBadGateway})
+	a, b := p.Pick(), p.Pick()
+	send(t, b, context.Background(), 4)
+	send(t, a, context.Background(), 4)
+	// 都被摘除时选最早恢复的那个
+	if e := p.Peek(); e != b {
+		t.Fatalf("应该选最早恢复的出口 b: %s", e.Name())
+	}
+}
@@ -0,0 +1,234 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 上游主机的熔断器处于打开状态，请求没有发出去
+var ErrCircuitOpen = errors.New("上游暂时不可用")
+
+// CircuitOpenError 熔断时返回的错误，errors.Is(err, ErrCircuitOpen) 为 true
+type CircuitOpenError struct {
+	Host string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrCircuitOpen.Error(), e.Host)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// ResilienceConfig 重试和熔断配置
+type ResilienceConfig struct {
+	// MaxRetries 幂等请求失败后最多重试几次，为 0 时不重试
+	MaxRetries int
+	// BaseBackoff 第一次重试前的等待时间，之后每次翻倍，不超过 MaxBackoff，实际等待时间会随机打散
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// FailureThreshold 同一个主机连续失败多少次后熔断，为 0 时不熔断
+	FailureThreshold int
+	// OpenDuration 熔断多久后放一个请求去探测上游是否恢复
+	OpenDuration time.Duration
+}
+
+type idempotentKey struct{}
+
+// Idempotent 标记 ctx 下的请求是幂等的，失败后可以重试。
+// 登录这类有副作用的请求不要标记
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	ok, _ := req.Context().Value(idempotentKey{}).(bool)
+	return ok
+}
+
+// Resilience 按上游主机熔断，并重试失败的幂等请求。熔断器在所有会话之间共享
+type Resilience struct {
+	cfg ResilienceConfig
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+}
+
+func NewResilience(cfg ResilienceConfig) *Resilience {
+	return &Resilience{
+		cfg:      cfg,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// Wrap 在 rt 外面加上重试和熔断
+func (r *Resilience) Wrap(rt http.RoundTripper) http.RoundTripper {
+	return &resilientTransport{r: r, rt: rt}
+}
+
+func (r *Resilience) breaker(host string) *breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.breakers[host]
+	if !ok {
+		b = &breaker{}
+		r.breakers[host] = b
+	}
+	return b
+}
+
+// backoff 第 attempt 次重试前的等待时间，在 [d/2, d) 之间随机
+func (r *Resilience) backoff(attempt int) time.Duration {
+	d := r.cfg.BaseBackoff << attempt
+	// 左移溢出时 d 会小于 BaseBackoff
+	if r.cfg.MaxBackoff > 0 && (d > r.cfg.MaxBackoff || d < r.cfg.BaseBackoff) {
+		d = r.cfg.MaxBackoff
+	}
+	if d < 2 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// Trip 外部探测发现 host 不可用时直接打开它的熔断器，不用等请求连续失败
+func (r *Resilience) Trip(host string) {
+	if r.cfg.FailureThreshold <= 0 {
+		return
+	}
+	b := r.breaker(host)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = max(b.failures, r.cfg.FailureThreshold)
+	b.openUntil = time.Now().Add(r.cfg.OpenDuration)
+}
+
+// Reset 外部探测发现 host 恢复时关闭它的熔断器
+func (r *Resilience) Reset(host string) {
+	b := r.breaker(host)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+type resilientTransport struct {
+	r  *Resilience
+	rt http.RoundTripper
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if isIdempotent(req) && (req.Body == nil || req.GetBody != nil) {
+		retries = t.r.cfg.MaxRetries
+	}
+	b := t.r.breaker(req.URL.Host)
+	for attempt := 0; ; attempt++ {
+		probe, ok := b.allow(t.r.cfg)
+		if !ok {
+			return nil, &CircuitOpenError{Host: req.URL.Host}
+		}
+		resp, err := t.rt.RoundTrip(req)
+		// 调用方取消的请求说明不了上游的状况
+		if err != nil && req.Context().Err() != nil {
+			b.release(probe)
+			return nil, err
+		}
+		failed := err != nil || isUnavailable(resp)
+		b.done(t.r.cfg, probe, failed)
+		if !failed || attempt >= retries {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(t.r.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// isUnavailable 上游或者网关暂时不可用
+func isUnavailable(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// breaker 一个主机的熔断器。连续失败达到阈值后打开，打开期间直接拒绝请求；
+// 过了 OpenDuration 后只放一个请求去探测，成功就关闭，失败就继续打开
+type breaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// probing 正在进行的探测请求的编号，0 表示没有探测。
+	// 熔断器关闭时放行的普通请求结束时不能清掉别人的探测
+	probing uint64
+	probes  uint64
+}
+
+// allow 返回请求能否发出；请求是半开状态下的探测时同时返回它的编号，结束时交给 done 或 release
+func (b *breaker) allow(cfg ResilienceConfig) (uint64, bool) {
+	if cfg.FailureThreshold <= 0 {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < cfg.FailureThreshold {
+		return 0, true
+	}
+	if b.probing != 0 || time.Now().Before(b.openUntil) {
+		return 0, false
+	}
+	b.probes++
+	b.probing = b.probes
+	return b.probing, true
+}
+
+func (b *breaker) done(cfg ResilienceConfig, probe uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.endProbe(probe)
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if cfg.FailureThreshold > 0 && b.failures >= cfg.FailureThreshold {
+		b.openUntil = time.Now().Add(cfg.OpenDuration)
+	}
+}
+
+// release 请求被调用方取消，不计入结果，只释放探测名额
+func (b *breaker) release(probe uint64) {
+	b.mu.Lock()
+	b.endProbe(probe)
+	b.mu.Unlock()
+}
+
+func (b *breaker) endProbe(probe uint64) {
+	if probe != 0 && b.probing == probe {
+		b.probing = 0
+	}
+}
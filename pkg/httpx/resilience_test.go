@@ -0,0 +1,184 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func respond(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(""))}
+}
+
+func get(t *testing.T, rt http.RoundTripper, ctx context.Context) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://xk.ccnu.edu.cn/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rt.RoundTrip(req)
+}
+
+func TestRetryIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	var bodies []string
+	r := NewResilience(ResilienceConfig{MaxRetries: 2, BaseBackoff: time.Millisecond})
+	rt := r.Wrap(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			b, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(b))
+		}
+		if calls.Add(1) < 3 {
+			return respond(http.StatusBadGateway), nil
+		}
+		return respond(http.StatusOK), nil
+	}))
+
+	resp, err := get(t, rt, Idempotent(context.Background()))
+	if err != nil || resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("幂等请求应该重试到成功: %v, %v, %d 次", resp, err, calls.Load())
+	}
+
+	// 重试时重新发送请求体
+	calls.Store(0)
+	req, _ := http.NewRequestWithContext(Idempotent(context.Background()), "POST", "http://xk.ccnu.edu.cn/", strings.NewReader("xnm=2023"))
+	if resp, err = rt.RoundTrip(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("幂等的 POST 也应该重试: %v, %v", resp, err)
+	}
+	if len(bodies) != 3 || bodies[2] != "xnm=2023" {
+		t.Fatalf("每次重试都应该带上请求体: %q", bodies)
+	}
+
+	// 没有标记幂等的请求不重试
+	calls.Store(0)
+	if resp, err = get(t, rt, context.Background()); err != nil || resp.StatusCode != http.StatusBadGateway || calls.Load() != 1 {
+		t.Fatalf("非幂等请求不应该重试: %v, %v, %d 次", resp, err, calls.Load())
+	}
+}
+
+func TestBreakerOpensAndRecovers(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	r := NewResilience(ResilienceConfig{FailureThreshold: 2, OpenDuration: 20 * time.Millisecond})
+	rt := r.Wrap(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return respond(int(status.Load())), nil
+	}))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := get(t, rt, ctx); err != nil {
+			t.Fatalf("第 %d 次还没有熔断: %v", i+1, err)
+		}
+	}
+	if _, err := get(t, rt, ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("连续失败两次后应该熔断: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	status.Store(http.StatusOK)
+	if _, err := get(t, rt, ctx); err != nil {
+		t.Fatalf("打开时间过后应该放一个探测请求: %v", err)
+	}
+	if _, err := get(t, rt, ctx); err != nil {
+		t.Fatalf("探测成功后应该关闭: %v", err)
+	}
+
+	r.Trip("xk.ccnu.edu.cn")
+	if _, err := get(t, rt, ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Trip 之后应该熔断: %v", err)
+	}
+	r.Reset("xk.ccnu.edu.cn")
+	if _, err := get(t, rt, ctx); err != nil {
+		t.Fatalf("Reset 之后应该关闭: %v", err)
+	}
+}
+
+// 熔断器关闭时放行、很晚才结束的普通请求，不能清掉半开状态下正在进行的探测，否则会同时放出第二个探测
+func TestBreakerStaleRequestKeepsProbe(t *testing.T) {
+	const openDuration = 20 * time.Millisecond
+	r := NewResilience(ResilienceConfig{FailureThreshold: 1, OpenDuration: openDuration})
+	stale, probe := make(chan struct{}), make(chan struct{})
+	probing := make(chan struct{})
+	rt := r.Wrap(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.Header.Get("X-Test") {
+		case "stale":
+			<-stale
+			return respond(http.StatusBadGateway), nil
+		case "probe":
+			close(probing)
+			<-probe
+			return respond(http.StatusOK), nil
+		}
+		return respond(http.StatusServiceUnavailable), nil
+	}))
+	send := func(name string) error {
+		req, _ := http.NewRequest("GET", "http://xk.ccnu.edu.cn/", nil)
+		req.Header.Set("X-Test", name)
+		resp, err := rt.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	staleDone := make(chan struct{})
+	go func() {
+		defer close(staleDone)
+		_ = send("stale")
+	}()
+	// 等 stale 请求发出去之后再打开熔断器
+	time.Sleep(5 * time.Millisecond)
+	if err := send("fail"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(openDuration + 10*time.Millisecond)
+	probeDone := make(chan error, 1)
+	go func() {
+		probeDone <- send("probe")
+	}()
+	<-probing
+
+	close(stale)
+	<-staleDone
+	time.Sleep(openDuration + 10*time.Millisecond)
+	if err := send("fail"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("探测还没有结束，不应该放出第二个探测: %v", err)
+	}
+
+	close(probe)
+	if err := <-probeDone; err != nil {
+		t.Fatal(err)
+	}
+	if err := send("ok"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("探测成功后应该关闭: %v", err)
+	}
+}
+
+func TestCanceledRequestsDoNotTrip(t *testing.T) {
+	r := NewResilience(ResilienceConfig{FailureThreshold: 1, OpenDuration: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rt := r.Wrap(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return respond(http.StatusOK), nil
+	}))
+	if _, err := get(t, rt, ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("应该返回取消: %v", err)
+	}
+	if _, err := get(t, rt, context.Background()); err != nil {
+		t.Fatalf("调用方取消的请求不应该计入失败: %v", err)
+	}
+}
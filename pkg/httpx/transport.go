@@ -0,0 +1,102 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportConfig 出站 HTTP 连接的配置
+type TransportConfig struct {
+	// 连接池大小，0 表示不限制
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+
+	// InsecureSkipVerify 不校验上游证书，只应该在证书有问题的测试环境里打开
+	InsecureSkipVerify bool
+	// MinTLSVersion 允许的最低 TLS 版本，如 1.0、1.2，为空时使用 Go 的默认值
+	MinTLSVersion string
+
+	// Proxy 出站代理，支持 http://、https://、socks5:// 和 socks5h://，可以带用户名密码，为空时直连
+	Proxy string
+	// LocalAddr 直连时使用的本机源 IP，为空时由系统选择
+	LocalAddr string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTransport 按配置创建 Transport，所有会话共用它来复用连接
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.MinTLSVersion != "" {
+		v, ok := tlsVersions[cfg.MinTLSVersion]
+		if !ok {
+			return nil, fmt.Errorf("不支持的 TLS 版本: %s", cfg.MinTLSVersion)
+		}
+		tlsCfg.MinVersion = v
+	}
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: time.Second * 30,
+	}
+	if cfg.LocalAddr != "" {
+		ip := net.ParseIP(cfg.LocalAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("源地址不合法: %s", cfg.LocalAddr)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	t := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       tlsCfg,
+	}
+	if cfg.Proxy != "" {
+		proxy, err := ParseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	return t, nil
+}
+
+// ParseProxy 解析并校验代理地址
+func ParseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址不合法: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("代理地址缺少 host: %s", raw)
+	}
+	return u, nil
+}
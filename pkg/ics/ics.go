@@ -0,0 +1,150 @@
+// Package ics 生成 RFC 5545 iCalendar 文档，只实现了课表导出用到的部分
+package ics
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Calendar 一个 VCALENDAR，所有事件都使用 Location 所在的时区
+type Calendar struct {
+	ProdID string
+	// Name 日历名，写在 X-WR-CALNAME 里，大多数日历应用会用它作为订阅名
+	Name     string
+	Location *time.Location
+	Events   []Event
+}
+
+// Event 一个 VEVENT
+type Event struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Start       time.Time
+	End         time.Time
+	// Until 不为零值时按周重复到 Until（含）
+	Until time.Time
+	// ExDates 重复范围内需要跳过的那几次的开始时间
+	ExDates []time.Time
+	// RDates 重复规则之外额外发生的那几次的开始时间，时长和第一次相同
+	RDates []time.Time
+}
+
+const (
+	localFormat = "20060102T150405"
+	utcFormat   = "20060102T150405Z"
+)
+
+// Encode 输出 iCalendar 文本，stamp 用作每个事件的 DTSTAMP
+func (c Calendar) Encode(stamp time.Time) []byte {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.prop("PRODID", c.ProdID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		w.prop("X-WR-CALNAME", escape(c.Name))
+	}
+	w.prop("X-WR-TIMEZONE", loc.String())
+	writeTimezone(w, loc)
+	for _, e := range c.Events {
+		w.line("BEGIN:VEVENT")
+		w.prop("UID", e.UID)
+		w.prop("DTSTAMP", stamp.UTC().Format(utcFormat))
+		w.prop("DTSTART;TZID="+loc.String(), e.Start.In(loc).Format(localFormat))
+		w.prop("DTEND;TZID="+loc.String(), e.End.In(loc).Format(localFormat))
+		if !e.Until.IsZero() {
+			// 带 TZID 的 DTSTART 对应的 UNTIL 必须是 UTC 时间
+			w.prop("RRULE", "FREQ=WEEKLY;UNTIL="+e.Until.UTC().Format(utcFormat))
+		}
+		if len(e.ExDates) > 0 {
+			w.prop("EXDATE;TZID="+loc.String(), joinTimes(e.ExDates, loc))
+		}
+		if len(e.RDates) > 0 {
+			w.prop("RDATE;TZID="+loc.String(), joinTimes(e.RDates, loc))
+		}
+		w.prop("SUMMARY", escape(e.Summary))
+		if e.Location != "" {
+			w.prop("LOCATION", escape(e.Location))
+		}
+		if e.Description != "" {
+			w.prop("DESCRIPTION", escape(e.Description))
+		}
+		w.line("END:VEVENT")
+	}
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes()
+}
+
+func joinTimes(times []time.Time, loc *time.Location) string {
+	res := make([]string, 0, len(times))
+	for _, t := range times {
+		res = append(res, t.In(loc).Format(localFormat))
+	}
+	return strings.Join(res, ",")
+}
+
+// writeTimezone 输出一个只有 STANDARD 的 VTIMEZONE，适用于没有夏令时的时区，比如 Asia/Shanghai
+func writeTimezone(w *writer, loc *time.Location) {
+	_, offset := time.Date(2000, 1, 1, 0, 0, 0, 0, loc).Zone()
+	name, _ := time.Date(2000, 1, 1, 0, 0, 0, 0, loc).Zone()
+	w.line("BEGIN:VTIMEZONE")
+	w.prop("TZID", loc.String())
+	w.line("BEGIN:STANDARD")
+	w.line("DTSTART:19700101T000000")
+	w.prop("TZOFFSETFROM", formatOffset(offset))
+	w.prop("TZOFFSETTO", formatOffset(offset))
+	w.prop("TZNAME", name)
+	w.line("END:STANDARD")
+	w.line("END:VTIMEZONE")
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape 转义 TEXT 类型的值
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) prop(name, value string) {
+	w.line(name + ":" + value)
+}
+
+// line 写一行，超过 75 个字节时折行，不会把一个 UTF-8 字符拆开
+func (w *writer) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(s[i]) {
+			i--
+		}
+		w.buf.WriteString(s[:i])
+		w.buf.WriteString("\r\n ")
+		s = s[i:]
+		// 续行开头的空格也算在 75 个字节里
+		limit = 74
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
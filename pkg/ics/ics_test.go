@@ -0,0 +1,87 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscape(t *testing.T) {
+	got := escape("a\\b;c,d\r\ne\nf")
+	if want := `a\\b\;c\,d\ne\nf`; got != want {
+		t.Fatalf("转义有误: got %s, want %s", got, want)
+	}
+}
+
+func TestLineFolding(t *testing.T) {
+	w := &writer{}
+	w.prop("SUMMARY", strings.Repeat("数据结构", 20))
+	lines := strings.Split(strings.TrimSuffix(w.buf.String(), "\r\n"), "\r\n")
+	if len(lines) < 2 {
+		t.Fatalf("应该折行: %q", lines)
+	}
+	for i, line := range lines {
+		if len(line) > 75 {
+			t.Fatalf("第 %d 行超过 75 个字节: %q", i, line)
+		}
+		if i > 0 && !strings.HasPrefix(line, " ") {
+			t.Fatalf("续行应该以空格开头: %q", line)
+		}
+		if !utf8.ValidString(line) {
+			t.Fatalf("折行拆开了 UTF-8 字符: %q", line)
+		}
+	}
+	if got := strings.ReplaceAll(w.buf.String(), "\r\n ", ""); got != "SUMMARY:"+strings.Repeat("数据结构", 20)+"\r\n" {
+		t.Fatalf("展开后内容有误: %q", got)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := func(day, hour int) time.Time {
+		return time.Date(2023, 9, day, hour, 0, 0, 0, shanghai)
+	}
+	cal := Calendar{
+		ProdID:   "-//test//CN",
+		Name:     "课表",
+		Location: shanghai,
+		Events: []Event{{
+			UID:     "uid-1",
+			Summary: "数据结构",
+			Start:   at(4, 8),
+			End:     at(4, 9),
+			Until:   at(25, 8),
+			ExDates: []time.Time{at(11, 8), at(18, 8)},
+			RDates:  []time.Time{at(23, 8)},
+		}, {
+			UID:     "uid-2",
+			Summary: "讲座",
+			Start:   at(5, 14),
+			End:     at(5, 16),
+		}},
+	}
+	doc := string(cal.Encode(time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)))
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//CN\r\n",
+		"X-WR-CALNAME:课表\r\n",
+		"TZOFFSETFROM:+0800\r\n",
+		"DTSTAMP:20230901T000000Z\r\n",
+		"DTSTART;TZID=CST:20230904T080000\r\n",
+		// UNTIL 换算成 UTC
+		"RRULE:FREQ=WEEKLY;UNTIL=20230925T000000Z\r\n",
+		"EXDATE;TZID=CST:20230911T080000,20230918T080000\r\n",
+		"RDATE;TZID=CST:20230923T080000\r\n",
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("缺少 %q\n%s", want, doc)
+		}
+	}
+	// 不重复的事件没有 RRULE
+	if n := strings.Count(doc, "RRULE"); n != 1 {
+		t.Errorf("只有第一个事件重复，RRULE 出现了 %d 次", n)
+	}
+	if !strings.HasSuffix(doc, "END:VEVENT\r\nEND:VCALENDAR\r\n") {
+		t.Errorf("结尾有误:\n%s", doc)
+	}
+}
@@ -0,0 +1,86 @@
+package identity
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Kind 学号/工号对应的身份类型
+type Kind int
+
+const (
+	Unknown Kind = iota
+	Undergraduate
+	Master
+	PhD
+	Staff
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Undergraduate:
+		return "undergraduate"
+	case Master:
+		return "master"
+	case PhD:
+		return "phd"
+	case Staff:
+		return "staff"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrMalformedId 学号格式不正确
+var ErrMalformedId = errors.New("学号格式错误")
+
+// idLen 华师的学号和工号都是 10 位数字
+const idLen = 10
+
+// Identity 从学号中解析出来的身份信息
+type Identity struct {
+	Id   string
+	Kind Kind
+	// Year 入学年份，教职工是入职年份
+	Year int
+}
+
+// Parse 解析学号。学号前四位是入学年份，第五位区分身份：本科是 2，硕士是 1，博士是 0，工号是 6 或 9
+func Parse(id string) (Identity, error) {
+	if len(id) != idLen {
+		return Identity{}, ErrMalformedId
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return Identity{}, ErrMalformedId
+		}
+	}
+	year, _ := strconv.Atoi(id[:4])
+	if year < 1900 {
+		return Identity{}, ErrMalformedId
+	}
+	var kind Kind
+	switch id[4] {
+	case '2':
+		kind = Undergraduate
+	case '1':
+		kind = Master
+	case '0':
+		kind = PhD
+	case '6', '9':
+		kind = Staff
+	default:
+		return Identity{}, ErrMalformedId
+	}
+	return Identity{Id: id, Kind: kind, Year: year}, nil
+}
+
+// IsGraduate 是否是研究生（硕士或博士）
+func (i Identity) IsGraduate() bool {
+	return i.Kind == Master || i.Kind == PhD
+}
+
+// IsStudent 是否是学生
+func (i Identity) IsStudent() bool {
+	return i.Kind == Undergraduate || i.IsGraduate()
+}
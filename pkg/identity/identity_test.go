@@ -0,0 +1,50 @@
+package identity
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		id       string
+		kind     Kind
+		year     int
+		graduate bool
+		student  bool
+	}{
+		{"2023214001", Undergraduate, 2023, false, true},
+		{"2022112345", Master, 2022, true, true},
+		{"2021012345", PhD, 2021, true, true},
+		{"2010600001", Staff, 2010, false, false},
+		{"1998900001", Staff, 1998, false, false},
+	}
+	for _, c := range cases {
+		id, err := Parse(c.id)
+		if err != nil {
+			t.Fatalf("%s: %v", c.id, err)
+		}
+		if id.Id != c.id || id.Kind != c.kind || id.Year != c.year {
+			t.Errorf("%s 解析有误: %+v", c.id, id)
+		}
+		if id.IsGraduate() != c.graduate || id.IsStudent() != c.student {
+			t.Errorf("%s 的身份判断有误: graduate=%v student=%v", c.id, id.IsGraduate(), id.IsStudent())
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, id := range []string{"", "202321400", "20232140011", "2023a14001", "0023214001", "2023514001", "２０２３214001"} {
+		if _, err := Parse(id); !errors.Is(err, ErrMalformedId) {
+			t.Errorf("%q 应该返回 ErrMalformedId: %v", id, err)
+		}
+	}
+}
+
+func TestKindString(t *testing.T) {
+	for kind, want := range map[Kind]string{Undergraduate: "undergraduate", Master: "master", PhD: "phd", Staff: "staff", Unknown: "unknown"} {
+		if got := kind.String(); got != want {
+			t.Errorf("%d: got %s, want %s", kind, got, want)
+		}
+	}
+}
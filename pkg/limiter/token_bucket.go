@@ -0,0 +1,69 @@
+package limiter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶，按固定速率补充令牌，最多积攒 burst 个
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket rate 必须大于 0，burst 至少为 1，否则 panic，调用方应该在加载配置时就校验
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	if rate <= 0 || burst < 1 {
+		panic(fmt.Sprintf("limiter: 无效的令牌桶参数 rate=%v burst=%d", rate, burst))
+	}
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow 尝试拿走一个令牌，拿不到时返回还需要等待多久才会有令牌
+func (b *TokenBucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// Refund 退还一个 Allow 拿走的令牌，用于后续检查没有通过、请求实际没有发出的情况
+func (b *TokenBucket) Refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// Full 令牌是否已经补满，补满的桶和新建的桶没有区别，可以回收
+func (b *TokenBucket) Full() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	return b.tokens >= b.burst
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
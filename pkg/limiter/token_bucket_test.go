@@ -0,0 +1,53 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := NewTokenBucket(1, 2)
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.Allow(); !ok {
+			t.Fatalf("第 %d 次应该拿到令牌", i+1)
+		}
+	}
+	ok, wait := b.Allow()
+	if ok || wait <= 0 || wait > time.Second {
+		t.Fatalf("令牌用完后应该等待不超过 1 秒: %v, %v", ok, wait)
+	}
+	if b.Full() {
+		t.Fatal("令牌还没有补满")
+	}
+
+	b.Refund()
+	if ok, _ = b.Allow(); !ok {
+		t.Fatal("退还的令牌应该能再拿到")
+	}
+	// 退还不会超过桶的容量
+	b.Refund()
+	b.Refund()
+	b.Refund()
+	for i := 0; i < 2; i++ {
+		b.Allow()
+	}
+	if ok, _ = b.Allow(); ok {
+		t.Fatal("令牌不应该超过 burst 个")
+	}
+}
+
+func TestNewTokenBucketValidates(t *testing.T) {
+	for _, c := range []struct {
+		rate  float64
+		burst int
+	}{{0, 1}, {-1, 1}, {1, 0}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("rate=%v burst=%d 应该 panic", c.rate, c.burst)
+				}
+			}()
+			NewTokenBucket(c.rate, c.burst)
+		}()
+	}
+}
@@ -0,0 +1,72 @@
+package metricsx
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"net/http"
+	"time"
+)
+
+// Collector 在每次抓取时输出自己的指标
+type Collector interface {
+	Collect(w *Writer)
+}
+
+// Server 暴露 /metrics 给 Prometheus 抓取
+type Server struct {
+	srv        *http.Server
+	collectors []Collector
+	l          logger.Logger
+}
+
+// NewServer addr 为空时 Start 不会启动
+func NewServer(addr string, l logger.Logger, collectors ...Collector) *Server {
+	s := &Server{
+		collectors: collectors,
+		l:          l,
+	}
+	if addr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", s.handle)
+		s.srv = &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: time.Second * 5,
+		}
+	}
+	return s
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mw := NewWriter(w)
+	for _, c := range s.collectors {
+		c.Collect(mw)
+	}
+	if err := mw.Err(); err != nil {
+		s.l.Warn("输出指标失败", logger.Error(err))
+	}
+}
+
+// Start 在后台监听
+func (s *Server) Start() {
+	if s.srv == nil {
+		return
+	}
+	go func() {
+		err := s.srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.l.Error("指标服务退出", logger.Error(err))
+		}
+	}()
+}
+
+func (s *Server) Stop() {
+	if s.srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	_ = s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,77 @@
+package metricsx
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sample 一个带标签的样本
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Writer 按 Prometheus 文本格式输出指标，出错后之后的写入都会被忽略，错误通过 Err 取出
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Gauge(name, help string, samples ...Sample) {
+	w.write(name, help, "gauge", samples)
+}
+
+func (w *Writer) Counter(name, help string, samples ...Sample) {
+	w.write(name, help, "counter", samples)
+}
+
+func (w *Writer) Err() error {
+	return w.err
+}
+
+func (w *Writer) write(name, help, typ string, samples []Sample) {
+	if w.err != nil || len(samples) == 0 {
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	for _, s := range samples {
+		sb.WriteString(name)
+		writeLabels(&sb, s.Labels)
+		sb.WriteByte(' ')
+		sb.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+		sb.WriteByte('\n')
+	}
+	_, w.err = io.WriteString(w.w, sb.String())
+}
+
+func writeLabels(sb *strings.Builder, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(labels[k]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrCredentialNotFound = errors.New("没有保存该学号的密码")
+
+// Credential 加密保存的学生密码
+type Credential struct {
+	StudentId string
+	// KeyId 加密时使用的密钥 id
+	KeyId      string
+	Ciphertext []byte
+	Ctime      int64
+	Utime      int64
+}
+
+// CredentialRepository 加密密码的存储，只负责存取，不关心加解密
+type CredentialRepository interface {
+	Upsert(ctx context.Context, c Credential) error
+	FindByStudentId(ctx context.Context, studentId string) (Credential, error)
+	Delete(ctx context.Context, studentId string) error
+	// FindNotEncryptedBy 按学号升序查找学号大于 after、且不是用 keyId 加密的记录，用于密钥轮换后重新加密
+	FindNotEncryptedBy(ctx context.Context, keyId string, after string, limit int) ([]Credential, error)
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// GORMCredentialRepository 保存在数据库里
+type GORMCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewGORMCredentialRepository(db *gorm.DB) CredentialRepository {
+	return &GORMCredentialRepository{db: db}
+}
+
+func (r *GORMCredentialRepository) Upsert(ctx context.Context, c Credential) error {
+	now := time.Now().UnixMilli()
+	m := CredentialModel{
+		StudentId:  c.StudentId,
+		KeyId:      c.KeyId,
+		Ciphertext: c.Ciphertext,
+		Ctime:      now,
+		Utime:      now,
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"key_id", "ciphertext", "utime"}),
+	}).Create(&m).Error
+}
+
+func (r *GORMCredentialRepository) FindByStudentId(ctx context.Context, studentId string) (Credential, error) {
+	var m CredentialModel
+	err := r.db.WithContext(ctx).Where("student_id = ?", studentId).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Credential{}, ErrCredentialNotFound
+	}
+	if err != nil {
+		return Credential{}, err
+	}
+	return r.toCredential(m), nil
+}
+
+func (r *GORMCredentialRepository) Delete(ctx context.Context, studentId string) error {
+	return r.db.WithContext(ctx).Where("student_id = ?", studentId).Delete(&CredentialModel{}).Error
+}
+
+func (r *GORMCredentialRepository) FindNotEncryptedBy(ctx context.Context, keyId string, after string, limit int) ([]Credential, error) {
+	var ms []CredentialModel
+	err := r.db.WithContext(ctx).
+		Where("key_id <> ? AND student_id > ?", keyId, after).
+		Order("student_id ASC").Limit(limit).Find(&ms).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Credential, 0, len(ms))
+	for _, m := range ms {
+		res = append(res, r.toCredential(m))
+	}
+	return res, nil
+}
+
+func (r *GORMCredentialRepository) toCredential(m CredentialModel) Credential {
+	return Credential{
+		StudentId:  m.StudentId,
+		KeyId:      m.KeyId,
+		Ciphertext: m.Ciphertext,
+		Ctime:      m.Ctime,
+		Utime:      m.Utime,
+	}
+}
+
+// CredentialModel 对应 credentials 表
+type CredentialModel struct {
+	StudentId  string `gorm:"primaryKey;type:varchar(20)"`
+	KeyId      string `gorm:"type:varchar(32);index"`
+	Ciphertext []byte `gorm:"type:varbinary(512)"`
+	Ctime      int64
+	Utime      int64
+}
+
+func (CredentialModel) TableName() string {
+	return "credentials"
+}
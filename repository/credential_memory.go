@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryCredentialRepository 保存在内存里，重启后丢失，适合开发环境
+type MemoryCredentialRepository struct {
+	mu    sync.RWMutex
+	creds map[string]Credential
+}
+
+func NewMemoryCredentialRepository() CredentialRepository {
+	return &MemoryCredentialRepository{
+		creds: make(map[string]Credential),
+	}
+}
+
+func (r *MemoryCredentialRepository) Upsert(ctx context.Context, c Credential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UnixMilli()
+	if old, ok := r.creds[c.StudentId]; ok {
+		c.Ctime = old.Ctime
+	} else {
+		c.Ctime = now
+	}
+	c.Utime = now
+	r.creds[c.StudentId] = c
+	return nil
+}
+
+func (r *MemoryCredentialRepository) FindByStudentId(ctx context.Context, studentId string) (Credential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.creds[studentId]
+	if !ok {
+		return Credential{}, ErrCredentialNotFound
+	}
+	return c, nil
+}
+
+func (r *MemoryCredentialRepository) Delete(ctx context.Context, studentId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.creds, studentId)
+	return nil
+}
+
+func (r *MemoryCredentialRepository) FindNotEncryptedBy(ctx context.Context, keyId string, after string, limit int) ([]Credential, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var res []Credential
+	for _, c := range r.creds {
+		if c.KeyId != keyId && c.StudentId > after {
+			res = append(res, c)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].StudentId < res[j].StudentId
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
@@ -0,0 +1,8 @@
+package repository
+
+import "gorm.io/gorm"
+
+// InitTables 建表
+func InitTables(db *gorm.DB) error {
+	return db.AutoMigrate(&CredentialModel{}, &LoginAttemptModel{})
+}
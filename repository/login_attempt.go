@@ -0,0 +1,25 @@
+package repository
+
+import "context"
+
+// LoginAttempt 一次 CAS 登录尝试，不记录密码
+type LoginAttempt struct {
+	Id        int64
+	StudentId string
+	// Caller 发起登录的调用方服务
+	Caller string
+	// Outcome 登录结果的分类，成功为 success，失败为错误原因
+	Outcome string
+	// LatencyMs 登录耗时，包括请求上游的时间
+	LatencyMs int64
+	Ctime     int64
+}
+
+// LoginAttemptRepository 登录审计记录的存储
+type LoginAttemptRepository interface {
+	Insert(ctx context.Context, a LoginAttempt) error
+	// Find 按时间倒序查找 [start, end) 之间的记录，时间单位是毫秒，studentId 为空时查所有学号
+	Find(ctx context.Context, studentId string, start, end int64, offset, limit int) ([]LoginAttempt, error)
+	// DeleteBefore 删除 before 之前的记录，返回删除的条数
+	DeleteBefore(ctx context.Context, before int64) (int64, error)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+// GORMLoginAttemptRepository 保存在数据库里
+type GORMLoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewGORMLoginAttemptRepository(db *gorm.DB) LoginAttemptRepository {
+	return &GORMLoginAttemptRepository{db: db}
+}
+
+func (r *GORMLoginAttemptRepository) Insert(ctx context.Context, a LoginAttempt) error {
+	m := LoginAttemptModel{
+		StudentId: a.StudentId,
+		Caller:    a.Caller,
+		Outcome:   a.Outcome,
+		LatencyMs: a.LatencyMs,
+		Ctime:     a.Ctime,
+	}
+	return r.db.WithContext(ctx).Create(&m).Error
+}
+
+func (r *GORMLoginAttemptRepository) Find(ctx context.Context, studentId string, start, end int64, offset, limit int) ([]LoginAttempt, error) {
+	query := r.db.WithContext(ctx).Where("ctime >= ? AND ctime < ?", start, end)
+	if studentId != "" {
+		query = query.Where("student_id = ?", studentId)
+	}
+	var ms []LoginAttemptModel
+	err := query.Order("ctime DESC, id DESC").Offset(offset).Limit(limit).Find(&ms).Error
+	if err != nil {
+		return nil, err
+	}
+	res := make([]LoginAttempt, 0, len(ms))
+	for _, m := range ms {
+		res = append(res, LoginAttempt{
+			Id:        m.Id,
+			StudentId: m.StudentId,
+			Caller:    m.Caller,
+			Outcome:   m.Outcome,
+			LatencyMs: m.LatencyMs,
+			Ctime:     m.Ctime,
+		})
+	}
+	return res, nil
+}
+
+func (r *GORMLoginAttemptRepository) DeleteBefore(ctx context.Context, before int64) (int64, error) {
+	res := r.db.WithContext(ctx).Where("ctime < ?", before).Delete(&LoginAttemptModel{})
+	return res.RowsAffected, res.Error
+}
+
+// LoginAttemptModel 对应 login_attempts 表
+type LoginAttemptModel struct {
+	Id        int64  `gorm:"primaryKey;autoIncrement"`
+	StudentId string `gorm:"type:varchar(20);index:idx_student_ctime"`
+	Caller    string `gorm:"type:varchar(64)"`
+	Outcome   string `gorm:"type:varchar(32)"`
+	LatencyMs int64
+	Ctime     int64 `gorm:"index:idx_student_ctime;index"`
+}
+
+func (LoginAttemptModel) TableName() string {
+	return "login_attempts"
+}
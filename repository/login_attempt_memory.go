@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// MemoryLoginAttemptRepository 保存在内存里，重启后丢失，适合开发环境
+type MemoryLoginAttemptRepository struct {
+	mu       sync.RWMutex
+	nextId   int64
+	attempts []LoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{}
+}
+
+func (r *MemoryLoginAttemptRepository) Insert(ctx context.Context, a LoginAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextId++
+	a.Id = r.nextId
+	r.attempts = append(r.attempts, a)
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Find(ctx context.Context, studentId string, start, end int64, offset, limit int) ([]LoginAttempt, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var res []LoginAttempt
+	for _, a := range r.attempts {
+		if studentId != "" && a.StudentId != studentId {
+			continue
+		}
+		if a.Ctime < start || a.Ctime >= end {
+			continue
+		}
+		res = append(res, a)
+	}
+	// 记录是异步写入的，插入顺序不一定是时间顺序
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Ctime != res[j].Ctime {
+			return res[i].Ctime > res[j].Ctime
+		}
+		return res[i].Id > res[j].Id
+	})
+	if offset >= len(res) {
+		return nil, nil
+	}
+	res = res[offset:]
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+func (r *MemoryLoginAttemptRepository) DeleteBefore(ctx context.Context, before int64) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.attempts[:0]
+	for _, a := range r.attempts {
+		if a.Ctime >= before {
+			kept = append(kept, a)
+		}
+	}
+	cnt := int64(len(r.attempts) - len(kept))
+	r.attempts = kept
+	return cnt, nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PeriodTime 一节课的上下课时间，格式为 15:04
+type PeriodTime struct {
+	Start string
+	End   string
+}
+
+// CalendarConfig 校历和作息时间
+type CalendarConfig struct {
+	// Periods 第 i 个元素是第 i+1 节课
+	Periods []PeriodTime
+	Terms   []TermConfig
+}
+
+// TermConfig 一个学期的校历，日期的格式都是 2006-01-02
+type TermConfig struct {
+	Year string
+	Term string
+	// Start 第一周周一的日期
+	Start    string
+	Weeks    int
+	Holidays []HolidayConfig
+	Workdays []WorkdayConfig
+}
+
+// HolidayConfig 一段放假的日期，To 为空时只放 From 这一天
+type HolidayConfig struct {
+	Name string
+	From string
+	To   string
+}
+
+// WorkdayConfig 调休上课，Date 这天按 As 那天的课表上课
+type WorkdayConfig struct {
+	Date string
+	As   string
+}
+
+// xkTermCodes 教务系统查询接口 xqm 参数的取值
+var xkTermCodes = map[string]string{"1": "3", "2": "12", "3": "16"}
+
+// shanghai 学校所在的时区，运行环境没有时区数据库时退化为固定的 UTC+8
+var shanghai = func() *time.Location {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		return time.FixedZone("Asia/Shanghai", 8*60*60)
+	}
+	return loc
+}()
+
+type academicTerm struct {
+	info domain.Term
+	// holidays 放假的日期到节日名的映射
+	holidays map[string]string
+	// workdays 调休上课的日期到按哪天课表上课的映射
+	workdays map[string]time.Time
+}
+
+// AcademicCalendar 校历，负责学年、学期、教学周和日期之间的换算。可以在运行时用 Reload 替换
+type AcademicCalendar struct {
+	mu      sync.RWMutex
+	periods []PeriodTime
+	// terms 按开学日期升序
+	terms []academicTerm
+}
+
+func NewAcademicCalendar(cfg CalendarConfig) (*AcademicCalendar, error) {
+	c := &AcademicCalendar{}
+	if err := c.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload 校验并替换整个校历，配置有误时保留原来的校历
+func (c *AcademicCalendar) Reload(cfg CalendarConfig) error {
+	for i, p := range cfg.Periods {
+		if _, err := time.Parse("15:04", p.Start); err != nil {
+			return fmt.Errorf("第 %d 节的上课时间有误: %s", i+1, p.Start)
+		}
+		if _, err := time.Parse("15:04", p.End); err != nil {
+			return fmt.Errorf("第 %d 节的下课时间有误: %s", i+1, p.End)
+		}
+	}
+	terms := make([]academicTerm, 0, len(cfg.Terms))
+	for _, tc := range cfg.Terms {
+		t, err := parseTerm(tc)
+		if err != nil {
+			return fmt.Errorf("%s 学年第 %s 学期: %w", tc.Year, tc.Term, err)
+		}
+		terms = append(terms, t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].info.Start.Before(terms[j].info.Start)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.periods = cfg.Periods
+	c.terms = terms
+	return nil
+}
+
+func parseTerm(tc TermConfig) (academicTerm, error) {
+	if _, ok := xkTermCodes[tc.Term]; !ok {
+		return academicTerm{}, fmt.Errorf("学期只能是 1、2、3")
+	}
+	start, err := parseDate(tc.Start)
+	if err != nil {
+		return academicTerm{}, err
+	}
+	if start.Weekday() != time.Monday {
+		return academicTerm{}, fmt.Errorf("开学日期 %s 不是周一", tc.Start)
+	}
+	if tc.Weeks <= 0 {
+		return academicTerm{}, fmt.Errorf("教学周数必须大于 0")
+	}
+	t := academicTerm{
+		info:     domain.Term{Year: tc.Year, Term: tc.Term, Start: start, Weeks: tc.Weeks},
+		holidays: make(map[string]string),
+		workdays: make(map[string]time.Time, len(tc.Workdays)),
+	}
+	for _, h := range tc.Holidays {
+		from, err := parseDate(h.From)
+		if err != nil {
+			return academicTerm{}, err
+		}
+		to := from
+		if h.To != "" {
+			if to, err = parseDate(h.To); err != nil {
+				return academicTerm{}, err
+			}
+		}
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			t.holidays[d.Format(time.DateOnly)] = h.Name
+		}
+	}
+	for _, w := range tc.Workdays {
+		date, err := parseDate(w.Date)
+		if err != nil {
+			return academicTerm{}, err
+		}
+		as, err := parseDate(w.As)
+		if err != nil {
+			return academicTerm{}, err
+		}
+		// 按哪天的课表上课只能是本学期内的日期，否则算不出是第几周
+		if as.Before(t.info.Start) || !as.Before(t.info.End()) {
+			return academicTerm{}, fmt.Errorf("调休 %s 按 %s 的课表上课，但 %s 不在本学期内", w.Date, w.As, w.As)
+		}
+		t.workdays[date.Format(time.DateOnly)] = as
+	}
+	return t, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	t, err := time.ParseInLocation(time.DateOnly, s, shanghai)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("日期格式有误: %s", s)
+	}
+	return t, nil
+}
+
+func (c *AcademicCalendar) Periods() []PeriodTime {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.periods
+}
+
+func (c *AcademicCalendar) Term(year, term string) (domain.Term, bool) {
+	t, ok := c.term(year, term)
+	return t.info, ok
+}
+
+func (c *AcademicCalendar) term(year, term string) (academicTerm, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, t := range c.terms {
+		if t.info.Year == year && t.info.Term == term {
+			return t, true
+		}
+	}
+	return academicTerm{}, false
+}
+
+// CurrentTerm now 所在的学期；假期中返回下一个学期，之后没有学期时返回最后一个学期
+func (c *AcademicCalendar) CurrentTerm(now time.Time) (domain.Term, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.terms) == 0 {
+		return domain.Term{}, false
+	}
+	for _, t := range c.terms {
+		if now.Before(t.info.End()) {
+			return t.info, true
+		}
+	}
+	return c.terms[len(c.terms)-1].info, true
+}
+
+// WeekOf date 是哪个学期的第几周星期几，不在任何学期内时返回 false
+func (c *AcademicCalendar) WeekOf(date time.Time) (domain.CalendarDay, bool) {
+	date = date.In(shanghai)
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, shanghai)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, t := range c.terms {
+		if day.Before(t.info.Start) || !day.Before(t.info.End()) {
+			continue
+		}
+		res := domain.CalendarDay{Date: day, Term: t.info}
+		key := day.Format(time.DateOnly)
+		res.HolidayName, res.Holiday = t.holidays[key]
+		schedule := day
+		if as, ok := t.workdays[key]; ok {
+			res.Makeup, schedule = true, as
+		}
+		days := int(schedule.Sub(t.info.Start).Hours()+12) / 24
+		res.Week, res.Weekday = days/7+1, days%7+1
+		return res, true
+	}
+	return domain.CalendarDay{}, false
+}
+
+// XKParams 把学年和学期换算成教务系统查询接口的 xnm、xqm 参数，year 为 0 时查询所有学年
+func (c *AcademicCalendar) XKParams(year, term string) (string, string) {
+	if year == "0" {
+		year = ""
+	}
+	return year, xkTermCodes[term]
+}
+
+func (c *ccnuService) GetCurrentTerm(ctx context.Context) (domain.Term, error) {
+	t, ok := c.calendar.CurrentTerm(time.Now())
+	if !ok {
+		return domain.Term{}, ccnuv1.ErrorUnknownTerm("校历中还没有任何学期")
+	}
+	return t, nil
+}
+
+func (c *ccnuService) GetWeekOf(ctx context.Context, date time.Time) (domain.CalendarDay, error) {
+	day, ok := c.calendar.WeekOf(date)
+	if !ok {
+		return domain.CalendarDay{}, ccnuv1.ErrorUnknownTerm("%s 不在校历中的任何学期内", date.In(shanghai).Format(time.DateOnly))
+	}
+	return day, nil
+}
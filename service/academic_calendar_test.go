@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"github.com/asynccnu/be-ccnu/service"
+	"testing"
+	"time"
+)
+
+func TestWeekOf(t *testing.T) {
+	cal, err := service.NewAcademicCalendar(testCalendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		date    string
+		week    int
+		weekday int
+		holiday bool
+		makeup  bool
+	}{
+		{date: "2023-09-04", week: 1, weekday: 1},
+		{date: "2023-09-10", week: 1, weekday: 7},
+		{date: "2023-10-02", week: 5, weekday: 1, holiday: true},
+		// 周六调休，按 10 月 6 日（第 5 周周五）的课表上课
+		{date: "2023-10-07", week: 5, weekday: 5, makeup: true},
+		{date: "2024-01-07", week: 18, weekday: 7},
+	}
+	for _, c := range cases {
+		date, _ := time.Parse(time.DateOnly, c.date)
+		day, ok := cal.WeekOf(date.Add(time.Hour * 10))
+		if !ok {
+			t.Fatalf("%s 应该在学期内", c.date)
+		}
+		if day.Week != c.week || day.Weekday != c.weekday || day.Holiday != c.holiday || day.Makeup != c.makeup {
+			t.Errorf("%s: got %+v", c.date, day)
+		}
+	}
+	for _, date := range []string{"2023-09-03", "2024-01-08"} {
+		d, _ := time.Parse(time.DateOnly, date)
+		if day, ok := cal.WeekOf(d); ok {
+			t.Errorf("%s 不在学期内，got %+v", date, day)
+		}
+	}
+}
+
+func TestCalendarRejectsMakeupOutsideTerm(t *testing.T) {
+	cfg := testCalendar
+	term := cfg.Terms[0]
+	term.Workdays = []service.WorkdayConfig{{Date: "2023-09-02", As: "2023-09-01"}}
+	cfg.Terms = []service.TermConfig{term}
+	if _, err := service.NewAcademicCalendar(cfg); err == nil {
+		t.Fatal("按学期外的日期调休应该报错")
+	}
+}
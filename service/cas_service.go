@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// knownServices 所有已知的接入 CAS 的系统，键是系统名，值是系统在 CAS 注册的 service 地址
+func (c *ccnuService) knownServices() map[string]string {
+	res := make(map[string]string, len(c.upstream.Services)+2)
+	for name, ep := range c.upstream.Services {
+		res[name] = ep.URL(nil)
+	}
+	res[c.undergraduate.name] = c.undergraduate.cfg.SSO.URL(nil)
+	res[c.graduate.name] = c.graduate.cfg.SSO.URL(nil)
+	return res
+}
+
+func (c *ccnuService) GetServiceCookies(ctx context.Context, studentId, password string, services []string) ([]domain.SystemCookies, error) {
+	known := c.knownServices()
+	if len(services) == 0 {
+		for name := range known {
+			services = append(services, name)
+		}
+		sort.Strings(services)
+	}
+	for _, name := range services {
+		if _, ok := known[name]; !ok {
+			return nil, ccnuv1.ErrorUnknownService("未知的系统: %s", name)
+		}
+	}
+
+	if !c.needsXK(ctx, services) {
+		return c.casServiceCookies(ctx, studentId, password, services, known)
+	}
+	var res []domain.SystemCookies
+	// 学生的 CAS 登录会话和教务系统的会话是同一个，TGT 失效时 doXK 会重新登录
+	err := c.doXK(ctx, studentId, password, func(sess *session) error {
+		var er error
+		res, er = c.serviceCookies(ctx, sess.client, services, known)
+		return er
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// needsXK 请求了教务系统，或者调用方带了会话令牌（令牌只绑定教务系统的会话）时，需要走教务系统的会话
+func (c *ccnuService) needsXK(ctx context.Context, services []string) bool {
+	if _, ok := sessionTokenFromContext(ctx); ok {
+		return true
+	}
+	for _, name := range services {
+		if name == c.undergraduate.name || name == c.graduate.name {
+			return true
+		}
+	}
+	return false
+}
+
+// casServiceCookies 只请求了教务系统之外的系统，只登录 CAS，不用单点登录教务系统，教职工也可以使用
+func (c *ccnuService) casServiceCookies(ctx context.Context, studentId, password string, services []string,
+	known map[string]string) ([]domain.SystemCookies, error) {
+	if _, err := parseStudentId(studentId); err != nil {
+		return nil, err
+	}
+	password, _, err := c.resolvePassword(ctx, studentId, password)
+	if err != nil {
+		return nil, err
+	}
+	client, err := c.loginClient(ctx, studentId, password)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.serviceCookies(ctx, client, services, known)
+	if errors.Is(err, errSessionExpired) {
+		return nil, ccnuv1.ErrorUnexpectedResponse("刚登录的 CAS 会话没有签发 service ticket")
+	}
+	return res, contextError(err)
+}
+
+// serviceCookies 用已经登录 CAS 的 client 依次登录 services 里的系统，按系统返回 Cookie
+func (c *ccnuService) serviceCookies(ctx context.Context, client *http.Client, services []string,
+	known map[string]string) ([]domain.SystemCookies, error) {
+	jar, ok := client.Jar.(*recordingJar)
+	if !ok {
+		return nil, errNoRecordingJar
+	}
+	res := make([]domain.SystemCookies, 0, len(services))
+	for _, name := range services {
+		if err := c.casServiceLogin(ctx, client, known[name]); err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(known[name])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, domain.SystemCookies{
+			System:  name,
+			Cookies: jar.HostCookies(u.Hostname()),
+		})
+	}
+	return res, nil
+}
+
+// casServiceLogin 使用已经登录 CAS 的 client 申请 service ticket，并带着 ticket 登录 service 对应的系统。
+// 系统下发的 Cookie 会保存在 client 的 Cookie Jar 里
+func (c *ccnuService) casServiceLogin(ctx context.Context, client *http.Client, service string) error {
+	request, err := http.NewRequestWithContext(ctx, "GET", c.upstream.CASLogin.URL(url.Values{"service": {service}}), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// TGT 失效时 CAS 不会签发 ticket，而是停在登录页
+	final := resp.Request.URL
+	if final.Host == c.upstream.CASLogin.Host && final.Path == c.upstream.CASLogin.Path {
+		return errSessionExpired
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func hasCookie(cookies []domain.Cookie, name string) bool {
+	for _, c := range cookies {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGetServiceCookies(t *testing.T) {
+	const staffId = "2010600001"
+	fake, svc := newTestService(t,
+		fakeccnu.Account{StudentId: undergraduateId, Password: password},
+		fakeccnu.Account{StudentId: staffId, Password: password},
+	)
+	ctx := context.Background()
+
+	// 只要教务系统之外的系统时不用单点登录教务系统
+	for _, id := range []string{undergraduateId, staffId} {
+		systems, err := svc.GetServiceCookies(ctx, id, password, []string{"library", "portal"})
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		if len(systems) != 2 || systems[0].System != "library" || systems[1].System != "portal" {
+			t.Fatalf("%s: 返回的系统有误: %+v", id, systems)
+		}
+		for _, sys := range systems {
+			if !hasCookie(sys.Cookies, "SESSION") {
+				t.Fatalf("%s: %s 没有登录成功: %+v", id, sys.System, sys.Cookies)
+			}
+		}
+	}
+	if got := fake.Hits(fakeccnu.RouteSSO); got != 0 {
+		t.Fatalf("不应该单点登录教务系统，实际 %d 次", got)
+	}
+	if got := fake.Hits(fakeccnu.RouteService); got != 4 {
+		t.Fatalf("每个系统应该登录一次，实际 %d 次", got)
+	}
+
+	systems, err := svc.GetServiceCookies(ctx, undergraduateId, password, []string{"jwglxt", "ecard"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(systems) != 2 || !hasCookie(systems[0].Cookies, "JSESSIONID") || !hasCookie(systems[1].Cookies, "SESSION") {
+		t.Fatalf("教务系统和一卡通的 Cookie 有误: %+v", systems)
+	}
+
+	if _, err = svc.GetServiceCookies(ctx, staffId, password, []string{"jwglxt"}); !ccnuv1.IsUnsupportedIdentity(err) {
+		t.Fatalf("教职工没有教务系统: %v", err)
+	}
+	if _, err = svc.GetServiceCookies(ctx, undergraduateId, password, []string{"nope"}); !ccnuv1.IsUnknownService(err) {
+		t.Fatalf("未知的系统应该报错: %v", err)
+	}
+	if _, err = svc.GetServiceCookies(ctx, staffId, "wrong", []string{"library"}); !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("密码错误应该报错: %v", err)
+	}
+}
@@ -2,74 +2,43 @@ package service
 
 import (
 	"context"
-	"fmt"
-	"net/http"
-	"strings"
+	"github.com/asynccnu/be-ccnu/domain"
+	"net/url"
 )
 
-func (s *ccnuService) GetCCNUCookie(ctx context.Context, studentId string, password string) (string, error) {
-	var cookie string
-	var err error
-	if CheckIsUndergraduate(studentId) {
-		cookie, err = BKSloginCCNU(studentId, password)
-		if err != nil {
-			return "", fmt.Errorf("failed to login: %v", err)
-		}
+// GetCCNUCookie 通过 CAS 单点登录教务系统，返回 CAS 和教务系统的全部 Cookie。
+// 本科生的教务系统是 jwglxt，研究生是 yjsxt
+func (c *ccnuService) GetCCNUCookie(ctx context.Context, studentId string, password string) ([]domain.SystemCookies, error) {
+	sess, err := c.xkSession(ctx, studentId, password)
+	if err != nil {
+		return nil, err
 	}
-	return cookie, nil
-}
-
-// CheckIsUndergraduate 检查该学号是否是本科生
-func CheckIsUndergraduate(stuId string) bool {
-	return stuId[4] == '2'
-	//区分是学号第五位，本科是2，硕士是1，博士是0，工号是6或9
+	return c.sessionCookies(sess)
 }
 
-// BKSloginCCNU 模拟本科生登录CCNU并返回Cookie
-func BKSloginCCNU(username, password string) (string, error) {
-	loginURL := "https://account.ccnu.edu.cn/cas/login" // 真实的登录URL
-	data := fmt.Sprintf("username=%s&password=%s", username, password)
-	req, err := http.NewRequest("POST", loginURL, strings.NewReader(data))
-	if err != nil {
-		return "", err
+// sessionCookies 按上游系统分组返回会话的 Cookie
+func (c *ccnuService) sessionCookies(sess *session) ([]domain.SystemCookies, error) {
+	jar, ok := sess.client.Jar.(*recordingJar)
+	if !ok {
+		return nil, errNoRecordingJar
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	targets := []struct {
+		system string
+		url    string
+	}{
+		{system: "cas", url: c.upstream.CASLogin.URL(nil)},
+		{system: sess.system.name, url: sess.system.cfg.Course.URL(nil)},
 	}
-	defer resp.Body.Close()
-	var value string
-	for _, cookie := range resp.Cookies() {
-		if cookie.Name == "JSESSIONID" {
-			value = cookie.Value
+	res := make([]domain.SystemCookies, 0, len(targets))
+	for _, t := range targets {
+		u, err := url.Parse(t.url)
+		if err != nil {
+			return nil, err
 		}
+		res = append(res, domain.SystemCookies{
+			System:  t.system,
+			Cookies: jar.Snapshot(u),
+		})
 	}
-	return fmt.Sprintf("JSESSIONID=%s", value), nil
+	return res, nil
 }
-
-//研究生登陆,暂时放弃
-//func YJSloginCCNU(id, mm string) (ccnuCookie string, err error) {
-//	client := &http.Client{}
-//	mp := make(map[string]string)
-//	str := fmt.Sprintf("csrftoken=&yhm=%s&mm=%s", id, mm)
-//	var data = strings.NewReader(str)
-//	timestamp := time.Now().Unix()
-//	url := fmt.Sprintf("https://grd.ccnu.edu.cn/yjsxt/xtgl/login_slogin.html?time=%d", timestamp)
-//	req, err := http.NewRequest("POST", url, data)
-//	if err != nil {
-//		return "", err
-//	}
-//	resp, err := client.Do(req)
-//	if err != nil {
-//		return "", err
-//	}
-//	defer resp.Body.Close()
-//	for _, v := range resp.Cookies() {
-//		mp[v.Name] = v.Value
-//	}
-//	ccnuCookie = fmt.Sprintf("JSESSIONID=%s; route=%s", mp["JSESSIONID"], mp["route"])
-//	return ccnuCookie, nil
-//}
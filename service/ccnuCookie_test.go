@@ -0,0 +1,31 @@
+package service_test
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestGetCCNUCookie(t *testing.T) {
+	_, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	systems, err := svc.GetCCNUCookie(context.Background(), undergraduateId, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(systems) != 2 || systems[0].System != "cas" || systems[1].System != "jwglxt" {
+		t.Fatalf("返回的系统有误: %+v", systems)
+	}
+	if !hasCookie(systems[0].Cookies, "CASTGC") || !hasCookie(systems[0].Cookies, "JSESSIONID") {
+		t.Fatalf("CAS 的 Cookie 有误: %+v", systems[0].Cookies)
+	}
+	if !hasCookie(systems[1].Cookies, "JSESSIONID") || !hasCookie(systems[1].Cookies, "route") {
+		t.Fatalf("教务系统的 Cookie 有误: %+v", systems[1].Cookies)
+	}
+	for _, sys := range systems {
+		for _, c := range sys.Cookies {
+			if c.Domain == "" || c.Path == "" {
+				t.Fatalf("%s 的 Cookie 缺少 Domain 或 Path: %+v", sys.System, c)
+			}
+		}
+	}
+}
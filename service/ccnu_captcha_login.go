@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/identity"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pendingLogin 等待调用方提交验证码的登录
+type pendingLogin struct {
+	studentId string
+	password  string
+	// system 为 nil 时是教职工，只登录 CAS
+	system *zfSystem
+	// client 和验证码绑定在同一个 CAS 会话上
+	client   *http.Client
+	params   *accountRequestParams
+	expireAt time.Time
+}
+
+// pendingLoginStore 保存等待验证码的登录，密码只在内存里短暂停留
+type pendingLoginStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	logins map[string]*pendingLogin
+}
+
+func newPendingLoginStore(ttl time.Duration) *pendingLoginStore {
+	return &pendingLoginStore{
+		ttl:    ttl,
+		logins: make(map[string]*pendingLogin),
+	}
+}
+
+func (s *pendingLoginStore) put(p *pendingLogin) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	handle := hex.EncodeToString(b)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, v := range s.logins {
+		if now.After(v.expireAt) {
+			delete(s.logins, k)
+		}
+	}
+	p.expireAt = now.Add(s.ttl)
+	s.logins[handle] = p
+	return handle, nil
+}
+
+// take 取出登录句柄对应的登录，句柄只能使用一次
+func (s *pendingLoginStore) take(handle string) (*pendingLogin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.logins[handle]
+	if !ok {
+		return nil, false
+	}
+	delete(s.logins, handle)
+	if time.Now().After(p.expireAt) {
+		return nil, false
+	}
+	return p, true
+}
+
+func (c *ccnuService) StartLogin(ctx context.Context, studentId string, password string) (challenge *domain.LoginChallenge, err error) {
+	if _, ok := c.sessions.get(studentId, password); ok {
+		c.auditCachedLogin(ctx, studentId, time.Now())
+		return nil, nil
+	}
+	start := time.Now()
+	defer func() {
+		err = contextError(err)
+		c.auditChallenge(ctx, studentId, start, challenge, err)
+	}()
+	system, err := c.loginSystemOf(studentId)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.limiter.Allow(studentId); err != nil {
+		return nil, err
+	}
+	client := c.client()
+	params, err := c.makeAccountPreflightRequest(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return c.tryLogin(ctx, &pendingLogin{
+		studentId: studentId,
+		password:  password,
+		system:    system,
+		client:    client,
+		params:    params,
+	}, "")
+}
+
+func (c *ccnuService) FinishLogin(ctx context.Context, handle string, captcha string) (challenge *domain.LoginChallenge, err error) {
+	p, ok := c.pendings.take(handle)
+	if !ok {
+		return nil, ccnuv1.ErrorInvalidLoginHandle("登录已过期，请重新登录")
+	}
+	start := time.Now()
+	defer func() {
+		err = contextError(err)
+		c.auditChallenge(ctx, p.studentId, start, challenge, err)
+	}()
+	if err = c.limiter.Allow(p.studentId); err != nil {
+		return nil, err
+	}
+	return c.tryLogin(ctx, p, captcha)
+}
+
+// auditChallenge 记录两阶段登录的一次尝试，返回了新的验证码挑战也算作需要验证码
+func (c *ccnuService) auditChallenge(ctx context.Context, studentId string, start time.Time,
+	challenge *domain.LoginChallenge, err error) {
+	if err == nil && challenge != nil {
+		err = ccnuv1.ErrorCaptchaRequired("需要输入验证码")
+	}
+	c.auditLogin(ctx, studentId, start, err)
+}
+
+// tryLogin 提交登录表单，需要验证码时返回新的验证码挑战，登录成功时缓存会话并返回 nil
+func (c *ccnuService) tryLogin(ctx context.Context, p *pendingLogin, captcha string) (*domain.LoginChallenge, error) {
+	if p.params.captchaField != "" && captcha == "" {
+		return c.newChallenge(ctx, p)
+	}
+	err := c.submitLogin(ctx, p.client, p.params, p.studentId, p.password, captcha)
+	if ccnuv1.IsCaptchaRequired(err) {
+		// 验证码错误，或者这次开始要求验证码，重新获取登录表单和验证码
+		params, er := c.makeAccountPreflightRequest(ctx, p.client)
+		if er != nil {
+			return nil, er
+		}
+		if params.captchaField == "" {
+			return nil, err
+		}
+		p.params = params
+		return c.newChallenge(ctx, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.system == nil {
+		return nil, nil
+	}
+	err = c.ssoLogin(ctx, p.client, p.system)
+	if err != nil {
+		return nil, err
+	}
+	c.sessions.put(p.studentId, p.password, p.system, p.client)
+	return nil, nil
+}
+
+// loginSystemOf 登录时要单点登录的教务系统，和 Login 一样，教职工没有教务系统，返回 nil 只登录 CAS
+func (c *ccnuService) loginSystemOf(studentId string) (*zfSystem, error) {
+	id, err := parseStudentId(studentId)
+	if err != nil {
+		return nil, err
+	}
+	if id.Kind == identity.Staff {
+		return nil, nil
+	}
+	return c.systemOf(studentId)
+}
+
+func (c *ccnuService) newChallenge(ctx context.Context, p *pendingLogin) (*domain.LoginChallenge, error) {
+	img, imgType, err := c.fetchCaptcha(ctx, p.client, p.params.captchaURL)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := c.pendings.put(p)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LoginChallenge{
+		Handle:    handle,
+		Image:     img,
+		ImageType: imgType,
+		ExpireAt:  p.expireAt,
+	}, nil
+}
+
+// fetchCaptcha 下载验证码图片
+func (c *ccnuService) fetchCaptcha(ctx context.Context, client *http.Client, captchaURL string) ([]byte, string, error) {
+	request, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "GET", captchaURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	img, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	imgType := resp.Header.Get("Content-Type")
+	if imgType == "" {
+		imgType = http.DetectContentType(img)
+	}
+	return img, imgType, nil
+}
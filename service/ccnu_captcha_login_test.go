@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestCaptchaLogin(t *testing.T) {
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	fake.RequireCaptchaAfter(1)
+	ctx := context.Background()
+
+	if _, err := svc.Login(ctx, undergraduateId, "wrong"); !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("第一次输错密码应该是密码错误: %v", err)
+	}
+	if _, err := svc.Login(ctx, undergraduateId, password); !ccnuv1.IsCaptchaRequired(err) {
+		t.Fatalf("输错密码之后应该要求验证码: %v", err)
+	}
+	challenge, err := svc.StartLogin(ctx, undergraduateId, password)
+	if err != nil || challenge == nil {
+		t.Fatalf("应该返回验证码挑战: %v, %v", challenge, err)
+	}
+	challenge, err = svc.FinishLogin(ctx, challenge.Handle, "wrong")
+	if err != nil || challenge == nil {
+		t.Fatalf("验证码错误时应该返回新的挑战: %v, %v", challenge, err)
+	}
+	if challenge, err = svc.FinishLogin(ctx, challenge.Handle, fakeccnu.CaptchaCode); challenge != nil || err != nil {
+		t.Fatalf("验证码正确时应该登录成功: %v, %v", challenge, err)
+	}
+	if _, err = svc.FinishLogin(ctx, "used", fakeccnu.CaptchaCode); !ccnuv1.IsInvalidLoginHandle(err) {
+		t.Fatalf("未知的句柄应该返回 INVALID_LOGIN_HANDLE: %v", err)
+	}
+}
+
+func TestStaffCaptchaLogin(t *testing.T) {
+	const staffId = "2010600001"
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: staffId, Password: password})
+	fake.RequireCaptchaAfter(1)
+	ctx := context.Background()
+
+	if _, err := svc.Login(ctx, staffId, "wrong"); !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("第一次输错密码应该是密码错误: %v", err)
+	}
+	challenge, err := svc.StartLogin(ctx, staffId, password)
+	if err != nil || challenge == nil {
+		t.Fatalf("教职工也应该能拿到验证码挑战: %v, %v", challenge, err)
+	}
+	if challenge, err = svc.FinishLogin(ctx, challenge.Handle, fakeccnu.CaptchaCode); challenge != nil || err != nil {
+		t.Fatalf("验证码正确时应该登录成功: %v, %v", challenge, err)
+	}
+	if got := fake.Hits(fakeccnu.RouteSSO); got != 0 {
+		t.Fatalf("教职工只登录 CAS，不应该单点登录教务系统，实际 %d 次", got)
+	}
+}
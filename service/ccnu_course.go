@@ -2,8 +2,8 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
 	"github.com/ecodeclub/ekit/slice"
 	"io"
 	"net/http"
@@ -33,7 +33,6 @@ type OriginalCourseItem struct {
 
 // GetSelfCourseList 个人课程列表
 func (c *ccnuService) GetSelfCourseList(ctx context.Context, studentId, password, year, term string) ([]domain.Course, error) {
-	// var termMap = map[string]string{"3": "1", "12": "2", "16": "3"} // 学期参数（逆向）
 	originalCourses, err := c.getSelfCoursesFromXK(ctx, studentId, password, year, term)
 	if err != nil {
 		return nil, err
@@ -70,36 +69,40 @@ func (c *ccnuService) getSelfCoursesFromXK(ctx context.Context, studentId, passw
 
 // makeCoursesGetRequest 请求获取课程列表
 func (c *ccnuService) makeCoursesGetRequest(ctx context.Context, studentId, password, year, term string) (OriginalCourses, error) {
-	var termMap = map[string]string{"1": "3", "2": "12", "3": "16"} // 学期参数
-	if year == "0" {
-		year = ""
-	}
+	var data OriginalCourses
+	err := c.doXK(ctx, studentId, password, func(sess *session) error {
+		var er error
+		data, er = c.queryCourses(ctx, sess, year, term)
+		return er
+	})
+	return data, err
+}
 
+func (c *ccnuService) queryCourses(ctx context.Context, sess *session, year, term string) (OriginalCourses, error) {
+	xnm, xqm := c.calendar.XKParams(year, term)
 	formData := url.Values{}
-	formData.Set("xnm", year)          // 学年名
-	formData.Set("xqm", termMap[term]) // 学期名
+	formData.Set("xnm", xnm) // 学年名
+	formData.Set("xqm", xqm) // 学期名
 	formData.Set("_search", "false")
-	formData.Set("nd", string(time.Now().UnixNano()))
+	formData.Set("nd", strconv.FormatInt(time.Now().UnixNano(), 10))
 	formData.Set("queryModel.showCount", "1000")
 	formData.Set("queryModel.currentPage", "1")
 	formData.Set("queryModel.sortName", "")
 	formData.Set("queryModel.sortOrder", "asc")
 	formData.Set("time", "5")
 
-	requestUrl := "http://xk.ccnu.edu.cn/jwglxt/xkcx/xkmdcx_cxXkmdcxIndex.html?doType=query&gnmkdm=N255010&su=" + studentId
-	req, err := http.NewRequest("POST", requestUrl, strings.NewReader(formData.Encode()))
+	requestUrl := sess.system.cfg.Course.URL(url.Values{"doType": {"query"}, "su": {sess.studentId}})
+	req, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "POST", requestUrl, strings.NewReader(formData.Encode()))
 	if err != nil {
 		return OriginalCourses{}, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
-	req.Header.Set("Origin", "http://xk.ccnu.edu.cn")
-	req.Header.Set("Host", "xk.ccnu.edu.cn")
+	req.Header.Set("Origin", sess.system.cfg.Course.Origin())
 	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/78.0.3904.108 Safari/537.36")
 
-	client, err := c.xkLoginClient(ctx, studentId, password)
-	resp, err := client.Do(req)
+	resp, err := sess.client.Do(req)
 	if err != nil {
 		return OriginalCourses{}, err
 	}
@@ -109,9 +112,12 @@ func (c *ccnuService) makeCoursesGetRequest(ctx context.Context, studentId, pass
 	if err != nil {
 		return OriginalCourses{}, err
 	}
+	if err := checkXKResponse(resp, body); err != nil {
+		return OriginalCourses{}, err
+	}
 
 	var data OriginalCourses
-	if err := json.Unmarshal(body, &data); err != nil {
+	if err := decodeXKJSON(body, &data); err != nil {
 		return OriginalCourses{}, err
 	}
 
@@ -119,20 +125,19 @@ func (c *ccnuService) makeCoursesGetRequest(ctx context.Context, studentId, pass
 }
 
 // xkLoginClient 教务系统模拟登录
-func (c *ccnuService) xkLoginClient(ctx context.Context, studentId string, password string) (*http.Client, error) {
+func (c *ccnuService) xkLoginClient(ctx context.Context, system *zfSystem, studentId string, password string) (*http.Client, error) {
 	client, err := c.loginClient(ctx, studentId, password)
 	if err != nil {
 		return nil, err
 	}
-	request, err := http.NewRequest("GET", "https://account.ccnu.edu.cn/cas/login?service=http%3A%2F%2Fxk.ccnu.edu.cn%2Fsso%2Fpziotlogin", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = client.Do(request)
+	err = c.ssoLogin(ctx, client, system)
 	if err != nil {
 		return nil, err
 	}
-
 	return client, nil
 }
+
+// ssoLogin 使用已经登录 CAS 的 client 单点登录到教务系统
+func (c *ccnuService) ssoLogin(ctx context.Context, client *http.Client, system *zfSystem) error {
+	return c.casServiceLogin(ctx, client, system.cfg.SSO.URL(nil))
+}
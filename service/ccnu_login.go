@@ -4,36 +4,80 @@ import (
 	"context"
 	"errors"
 	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/pkg/identity"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"io"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"time"
 )
 
 func (c *ccnuService) Login(ctx context.Context, studentId string, password string) (bool, error) {
-	client, err := c.loginClient(ctx, studentId, password)
-	return client != nil, err
+	id, err := parseStudentId(studentId)
+	if err != nil {
+		return false, err
+	}
+	if id.Kind == identity.Staff {
+		// 教职工没有教务系统，只校验 CAS 的账号密码
+		password, _, err = c.resolvePassword(ctx, studentId, password)
+		if err != nil {
+			return false, err
+		}
+		client, err := c.loginClient(ctx, studentId, password)
+		return client != nil, err
+	}
+	// 顺便把会话缓存下来，后续的查询就不用再登录一次了
+	start := time.Now()
+	sess, cached, err := c.passwordSession(ctx, studentId, password)
+	if cached {
+		// 命中缓存时不会走 CAS 登录，这里单独记一条
+		c.auditCachedLogin(ctx, studentId, start)
+	}
+	return sess != nil, contextError(err)
 }
 
+// client 创建一个新的 client，会话之后的请求都走这里选定的出口，Cookie Jar 每个会话单独一个
 func (c *ccnuService) client() *http.Client {
-	j, _ := cookiejar.New(&cookiejar.Options{})
 	return &http.Client{
-		Transport: nil,
+		Transport: c.resilience.Wrap(c.egress.Pick()),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return nil
 		},
-		Jar:     j,
+		Jar:     newRecordingJar(),
 		Timeout: c.timeout,
 	}
 }
 
-func (c *ccnuService) loginClient(ctx context.Context, studentId string, password string) (*http.Client, error) {
-	params, err := c.makeAccountPreflightRequest()
+func (c *ccnuService) loginClient(ctx context.Context, studentId string, password string) (_ *http.Client, err error) {
+	start := time.Now()
+	defer func() {
+		err = contextError(err)
+		c.auditLogin(ctx, studentId, start, err)
+	}()
+	if err = c.limiter.Allow(studentId); err != nil {
+		return nil, err
+	}
+	client := c.client()
+	params, err := c.makeAccountPreflightRequest(ctx, client)
 	if err != nil {
 		return nil, err
 	}
+	if params.captchaField != "" {
+		// 需要验证码时只能走 StartLogin、FinishLogin 两阶段登录
+		return nil, ccnuv1.ErrorCaptchaRequired("需要输入验证码")
+	}
+	err = c.submitLogin(ctx, client, params, studentId, password, "")
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
 
+// submitLogin 提交 CAS 登录表单，client 必须是做过 preflight 的那个
+func (c *ccnuService) submitLogin(ctx context.Context, client *http.Client, params *accountRequestParams,
+	studentId, password, captcha string) error {
 	v := url.Values{}
 	v.Set("username", studentId)
 	v.Set("password", password)
@@ -41,39 +85,41 @@ func (c *ccnuService) loginClient(ctx context.Context, studentId string, passwor
 	v.Set("execution", params.execution)
 	v.Set("_eventId", params._eventId)
 	v.Set("submit", params.submit)
+	if params.captchaField != "" {
+		v.Set(params.captchaField, captcha)
+	}
 
-	request, err := http.NewRequest("POST", "https://account.ccnu.edu.cn/cas/login;jsessionid="+params.JSESSIONID, strings.NewReader(v.Encode()))
+	loginURL := c.upstream.CASLogin
+	loginURL.Path += ";jsessionid=" + params.JSESSIONID
+	request, err := http.NewRequestWithContext(ctx, "POST", loginURL.URL(nil), strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_13_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/72.0.3626.109 Safari/537.36")
-	request.WithContext(ctx)
 
-	client := c.client()
 	resp, err := client.Do(request)
 	if err != nil {
+		if er := contextError(err); er != err {
+			return er
+		}
 		var opErr *net.OpError
 		if errors.As(err, &opErr) {
-			return nil, ccnuv1.ErrorNetworkToXkError("网络异常")
+			return ccnuv1.ErrorNetworkToXkError("网络异常")
 		}
-		return nil, err
+		return err
 	}
-	if len(resp.Header.Get("Set-Cookie")) == 0 {
-		return nil, ccnuv1.ErrorInvalidSidOrPwd("学号或密码错误")
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
 	}
-	return client, nil
-}
-
-type ClientKey struct{} // 用于 context 的键
-
-// 将 http.Client 添加到 context 中
-func (c *ccnuService) addClientToContext(ctx context.Context, client *http.Client) context.Context {
-	return context.WithValue(ctx, ClientKey{}, client)
-}
-
-// 从 context 中获取 http.Client
-func (c *ccnuService) getClientFromContext(ctx context.Context) *http.Client {
-	client, ok := ctx.Value(ClientKey{}).(*http.Client)
-	if !ok {
-		return nil // 这里可以处理默认逻辑或错误
+	err = classifyCASLogin(resp, string(body), hasCASTGC(client, c.upstream.CASLogin.URL(nil)))
+	c.limiter.Record(studentId, err)
+	if err != nil {
+		c.l.Info("CAS 登录失败", logger.String("studentId", studentId),
+			logger.String("msg", casErrorMsg(string(body))), logger.Error(err))
+		return err
 	}
-	return client
+	return nil
 }
@@ -0,0 +1,111 @@
+package service
+
+import (
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// CAS 页面上各种失败情况的提示文案，命中任意一个即认为是对应的失败
+var (
+	casMaintenanceHints = []string{"系统维护", "维护中", "暂停服务", "Service Unavailable"}
+	casLockedHints      = []string{"锁定", "冻结", "locked"}
+	casCaptchaHints     = []string{"验证码", "captcha"}
+	casPwdExpiredHints  = []string{"密码已过期", "密码过期", "修改初始密码", "强制修改密码", "请修改密码"}
+	casBadPwdHints      = []string{"用户名或密码", "账号或密码", "密码错误", "认证失败", "Invalid credentials"}
+)
+
+// 密码过期或者必须修改初始密码时，CAS 会跳到修改密码的页面（路径里带这些词，不区分大小写），
+// 或者直接返回修改密码的表单。只在这两种情况下才看页面里的过期提示，避免公告里的“请修改密码”被误判
+var (
+	casPwdChangePaths   = []string{"pwdchange", "changepassword", "mustchangepass", "expiredpass", "passwordmanagement"}
+	casPwdChangeMarkers = []string{`id="passwordManagementForm"`, `name="confirmedPassword"`, `name="newPassword"`}
+)
+
+// isCASPasswordChange 判断 CAS 是否要求先修改密码
+func isCASPasswordChange(resp *http.Response, body string) bool {
+	if resp.Request != nil && resp.Request.URL != nil &&
+		containsAny(strings.ToLower(resp.Request.URL.Path), casPwdChangePaths) {
+		return true
+	}
+	return containsAny(body, casPwdChangeMarkers)
+}
+
+// casMsgReg CAS 登录失败后，错误信息展示在 id="msg" 的元素里
+var casMsgReg = regexp.MustCompile(`id="msg"[^>]*>\s*([^<]*?)\s*<`)
+
+// casErrorMsg 提取 CAS 页面上的错误提示
+func casErrorMsg(body string) string {
+	arr := casMsgReg.FindStringSubmatch(body)
+	if len(arr) != 2 {
+		return ""
+	}
+	return arr[1]
+}
+
+// isCASMaintenance 判断 CAS 是否在维护或者不可用。只看 5xx 和 id="msg" 里的提示，
+// 正常页面的公告里也可能提到“系统维护”
+func isCASMaintenance(resp *http.Response, body string) bool {
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return true
+	}
+	return containsAny(casErrorMsg(body), casMaintenanceHints)
+}
+
+// classifyCASLogin 根据提交登录表单后 CAS 返回的页面判断登录结果，登录成功返回 nil。
+// loggedIn 表示 Cookie Jar 里是否已经有了 CASTGC，重定向之后最终响应上不一定还带着 Set-Cookie
+func classifyCASLogin(resp *http.Response, body string, loggedIn bool) error {
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return ccnuv1.ErrorCasMaintenance("CAS 系统维护中")
+	}
+	if isCASPasswordChange(resp, body) {
+		return ccnuv1.ErrorPasswordExpired("密码已过期，请先修改密码")
+	}
+	if loggedIn {
+		return nil
+	}
+	if isCASMaintenance(resp, body) {
+		return ccnuv1.ErrorCasMaintenance("CAS 系统维护中")
+	}
+	if msg := casErrorMsg(body); msg != "" {
+		switch {
+		case containsAny(msg, casPwdExpiredHints):
+			return ccnuv1.ErrorPasswordExpired("密码已过期，请先修改密码")
+		case containsAny(msg, casLockedHints):
+			return ccnuv1.ErrorAccountLocked("账号已被锁定")
+		case containsAny(msg, casCaptchaHints):
+			return ccnuv1.ErrorCaptchaRequired("需要输入验证码")
+		case containsAny(msg, casBadPwdHints):
+			return ccnuv1.ErrorInvalidSidOrPwd("学号或密码错误")
+		}
+	}
+	return ccnuv1.ErrorInvalidSidOrPwd("学号或密码错误")
+}
+
+// hasCASTGC 判断 client 是否已经拿到了 CAS 的登录凭证
+func hasCASTGC(client *http.Client, casLogin string) bool {
+	if client.Jar == nil {
+		return false
+	}
+	u, err := url.Parse(casLogin)
+	if err != nil {
+		return false
+	}
+	for _, cookie := range client.Jar.Cookies(u) {
+		if cookie.Name == "CASTGC" {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
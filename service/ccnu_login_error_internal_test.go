@@ -0,0 +1,59 @@
+package service
+
+import (
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+)
+
+func TestClassifyCASLogin(t *testing.T) {
+	const (
+		notice = `<div class="notice">本周六凌晨系统维护，届时暂停服务</div>`
+		form   = `<form id="fm1"><input name="lt" value="LT-1"/></form>`
+	)
+	cases := []struct {
+		name     string
+		status   int
+		body     string
+		loggedIn bool
+		is       func(error) bool
+	}{
+		{"公告里提到维护但已经登录", 200, notice, true, nil},
+		{"错误提示里说在维护", 200, `<div id="msg" class="errors">系统维护中，请稍后再试</div>` + form, false, ccnuv1.IsCasMaintenance},
+		{"5xx", 503, "", false, ccnuv1.IsCasMaintenance},
+		{"5xx 时即使有 CASTGC", 502, "", true, ccnuv1.IsCasMaintenance},
+		{"公告里提到维护但没有登录", 200, notice + form, false, ccnuv1.IsInvalidSidOrPwd},
+		{"密码错误", 200, `<div id="msg" class="errors">用户名或密码错误</div>` + form, false, ccnuv1.IsInvalidSidOrPwd},
+		{"没有错误提示也没有 CASTGC", 200, form, false, ccnuv1.IsInvalidSidOrPwd},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := classifyCASLogin(&http.Response{StatusCode: c.status}, c.body, c.loggedIn)
+			if c.is == nil {
+				if err != nil {
+					t.Fatalf("应该登录成功: %v", err)
+				}
+				return
+			}
+			if !c.is(err) {
+				t.Fatalf("登录结果有误: %v", err)
+			}
+		})
+	}
+}
+
+func TestHasCASTGC(t *testing.T) {
+	const casLogin = "https://account.ccnu.edu.cn/cas/login"
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	if hasCASTGC(client, casLogin) || hasCASTGC(&http.Client{}, casLogin) {
+		t.Fatal("还没有 CASTGC")
+	}
+	u, _ := url.Parse(casLogin)
+	jar.SetCookies(u, []*http.Cookie{{Name: "CASTGC", Value: "TGT-1", Path: "/cas"}})
+	if !hasCASTGC(client, casLogin+";jsessionid=abc") {
+		t.Fatal("Jar 里已经有 CASTGC")
+	}
+}
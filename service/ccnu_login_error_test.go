@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestLoginClassifiesCASFailures(t *testing.T) {
+	const (
+		lockedId  = "2023214002"
+		expiredId = "2023214003"
+	)
+	fake, svc := newTestService(t,
+		fakeccnu.Account{StudentId: undergraduateId, Password: password},
+		fakeccnu.Account{StudentId: lockedId, Password: password, Locked: true},
+		fakeccnu.Account{StudentId: expiredId, Password: password, PasswordExpired: true},
+	)
+	ctx := context.Background()
+
+	if ok, err := svc.Login(ctx, undergraduateId, password); !ok || err != nil {
+		t.Fatalf("正确的密码应该登录成功: %v, %v", ok, err)
+	}
+	cases := []struct {
+		name      string
+		studentId string
+		password  string
+		is        func(error) bool
+	}{
+		{"密码错误", undergraduateId, "wrong", ccnuv1.IsInvalidSidOrPwd},
+		{"账号锁定", lockedId, password, ccnuv1.IsAccountLocked},
+		{"密码过期", expiredId, password, ccnuv1.IsPasswordExpired},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ok, err := svc.Login(ctx, c.studentId, c.password)
+			if ok || !c.is(err) {
+				t.Fatalf("登录结果有误: %v, %v", ok, err)
+			}
+		})
+	}
+
+	t.Run("CAS 维护", func(t *testing.T) {
+		fake.Inject(fakeccnu.Fault{Route: fakeccnu.RouteCASLogin, Status: 503, Body: fakeccnu.MaintenancePage})
+		defer fake.ClearFaults()
+		if _, err := svc.Login(ctx, "2023214009", password); !ccnuv1.IsCasMaintenance(err) {
+			t.Fatalf("应该识别为 CAS 维护: %v", err)
+		}
+	})
+
+	t.Run("登录页换成了维护页", func(t *testing.T) {
+		fake.Inject(fakeccnu.Fault{Route: fakeccnu.RouteCASLogin, Body: fakeccnu.MaintenancePage})
+		defer fake.ClearFaults()
+		if _, err := svc.Login(ctx, "2023214009", password); !ccnuv1.IsCasMaintenance(err) {
+			t.Fatalf("应该识别为 CAS 维护: %v", err)
+		}
+	})
+}
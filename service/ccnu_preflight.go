@@ -1,10 +1,14 @@
 package service
 
 import (
+	"context"
 	"errors"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
 	"io"
 	"net/http"
 	"regexp"
+	"strings"
 )
 
 type accountRequestParams struct {
@@ -13,9 +17,19 @@ type accountRequestParams struct {
 	_eventId   string
 	submit     string
 	JSESSIONID string
+	// 多次登录失败后 CAS 会要求输入验证码，此时表单里会多出验证码字段
+	captchaField string
+	captchaURL   string
 }
 
-func (c *ccnuService) makeAccountPreflightRequest() (*accountRequestParams, error) {
+var (
+	captchaFieldReg = regexp.MustCompile(`<input[^>]+name="(captcha|authcode|validateCode)"`)
+	captchaImgReg   = regexp.MustCompile(`<img[^>]+src="([^"]*(?:captcha|authcode|validateCode)[^"]*)"`)
+)
+
+// makeAccountPreflightRequest 请求 CAS 登录页，获取登录表单的参数。
+// 验证码和 JSESSIONID 绑定，所以后续的登录请求必须使用同一个 client
+func (c *ccnuService) makeAccountPreflightRequest(ctx context.Context, client *http.Client) (*accountRequestParams, error) {
 	var JSESSIONID string
 	var lt string
 	var execution string
@@ -24,13 +38,13 @@ func (c *ccnuService) makeAccountPreflightRequest() (*accountRequestParams, erro
 	params := &accountRequestParams{}
 
 	// 初始化 http request
-	request, err := http.NewRequest("GET", "https://account.ccnu.edu.cn/cas/login", nil)
+	request, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "GET", c.upstream.CASLogin.URL(nil), nil)
 	if err != nil {
 		return params, err
 	}
 
 	// 发起请求
-	resp, err := c.client().Do(request)
+	resp, err := client.Do(request)
 	if err != nil {
 		return params, err
 	}
@@ -43,6 +57,13 @@ func (c *ccnuService) makeAccountPreflightRequest() (*accountRequestParams, erro
 		return params, err
 	}
 
+	bodyStr := string(body)
+	// 登录页上没有登录表单却有维护提示，说明整个登录页都被换成了维护页
+	if isCASMaintenance(resp, bodyStr) ||
+		!strings.Contains(bodyStr, `name="lt"`) && containsAny(bodyStr, casMaintenanceHints) {
+		return params, ccnuv1.ErrorCasMaintenance("CAS 系统维护中")
+	}
+
 	// 获取 Cookie 中的 JSESSIONID
 	for _, cookie := range resp.Cookies() {
 		if cookie.Name == "JSESSIONID" {
@@ -59,8 +80,6 @@ func (c *ccnuService) makeAccountPreflightRequest() (*accountRequestParams, erro
 	executionReg := regexp.MustCompile("name=\"execution\".+value=\"(.+)\"")
 	_eventIdReg := regexp.MustCompile("name=\"_eventId\".+value=\"(.+)\"")
 
-	bodyStr := string(body)
-
 	ltArr := ltReg.FindStringSubmatch(bodyStr)
 	if len(ltArr) != 2 {
 		return params, errors.New("Can not get form paramater: lt")
@@ -85,5 +104,18 @@ func (c *ccnuService) makeAccountPreflightRequest() (*accountRequestParams, erro
 	params.submit = "LOGIN"
 	params.JSESSIONID = JSESSIONID
 
+	if fieldArr := captchaFieldReg.FindStringSubmatch(bodyStr); len(fieldArr) == 2 {
+		imgArr := captchaImgReg.FindStringSubmatch(bodyStr)
+		if len(imgArr) != 2 {
+			return params, errors.New("Can not get captcha image")
+		}
+		imgURL, err := request.URL.Parse(imgArr[1])
+		if err != nil {
+			return params, err
+		}
+		params.captchaField = fieldArr[1]
+		params.captchaURL = imgURL.String()
+	}
+
 	return params, nil
 }
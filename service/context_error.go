@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"errors"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	kerrors "github.com/go-kratos/kratos/v2/errors"
+)
+
+// contextError 把调用方取消或者超时导致的错误转换成对应的 gRPC 状态（Canceled、DeadlineExceeded），
+// 上游被熔断时返回 UPSTREAM_UNAVAILABLE，其它错误原样返回
+func contextError(err error) error {
+	var openErr *httpx.CircuitOpenError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &openErr):
+		return ccnuv1.ErrorUpstreamUnavailable("%s 暂时不可用，请稍后再试", openErr.Host)
+	case errors.Is(err, context.Canceled):
+		return kerrors.ClientClosed("CANCELED", "请求已取消")
+	case errors.Is(err, context.DeadlineExceeded):
+		return kerrors.GatewayTimeout("DEADLINE_EXCEEDED", "请求超时")
+	}
+	return err
+}
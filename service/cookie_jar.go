@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"github.com/asynccnu/be-ccnu/domain"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoRecordingJar = errors.New("会话没有记录 Cookie")
+
+// recordingJar 在 cookiejar 外面包一层，把上游下发的 Cookie 连同 Domain、Path、过期时间一起记下来。
+// 标准库的 cookiejar 取出来的 Cookie 只有名字和值，下游的爬虫需要完整的 Cookie
+type recordingJar struct {
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies map[cookieKey]domain.Cookie
+}
+
+type cookieKey struct {
+	domain string
+	path   string
+	name   string
+}
+
+func newRecordingJar() *recordingJar {
+	j, _ := cookiejar.New(&cookiejar.Options{})
+	return &recordingJar{
+		jar:     j,
+		cookies: make(map[cookieKey]domain.Cookie),
+	}
+}
+
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		cookie := domain.Cookie{
+			Name:   c.Name,
+			Value:  c.Value,
+			Domain: strings.TrimPrefix(strings.ToLower(c.Domain), "."),
+			Path:   c.Path,
+		}
+		if cookie.Domain == "" {
+			cookie.Domain = u.Hostname()
+		}
+		if cookie.Path == "" || cookie.Path[0] != '/' {
+			cookie.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			cookie.ExpireAt = now
+		case c.MaxAge > 0:
+			cookie.ExpireAt = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			cookie.ExpireAt = c.Expires
+		}
+		key := cookieKey{domain: cookie.Domain, path: cookie.Path, name: cookie.Name}
+		if !cookie.ExpireAt.IsZero() && !cookie.ExpireAt.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		j.cookies[key] = cookie
+	}
+}
+
+func (j *recordingJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Snapshot 返回请求 u 时会带上的 Cookie 的完整信息
+func (j *recordingJar) Snapshot(u *url.URL) []domain.Cookie {
+	sent := make(map[string]string)
+	for _, c := range j.jar.Cookies(u) {
+		sent[c.Name] = c.Value
+	}
+	host := u.Hostname()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var res []domain.Cookie
+	for _, c := range j.cookies {
+		if v, ok := sent[c.Name]; !ok || v != c.Value {
+			continue
+		}
+		if host != c.Domain && !strings.HasSuffix(host, "."+c.Domain) {
+			continue
+		}
+		if !strings.HasPrefix(u.Path, c.Path) {
+			continue
+		}
+		res = append(res, c)
+	}
+	sortCookies(res)
+	return res
+}
+
+// HostCookies 返回 host 下所有没有过期的 Cookie，不区分 Path
+func (j *recordingJar) HostCookies(host string) []domain.Cookie {
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var res []domain.Cookie
+	for _, c := range j.cookies {
+		if host != c.Domain && !strings.HasSuffix(host, "."+c.Domain) {
+			continue
+		}
+		if !c.ExpireAt.IsZero() && !c.ExpireAt.After(now) {
+			continue
+		}
+		res = append(res, c)
+	}
+	sortCookies(res)
+	return res
+}
+
+// sortCookies 和 cookiejar 一样，Path 更长的排在前面
+func sortCookies(cookies []domain.Cookie) {
+	sort.Slice(cookies, func(a, b int) bool {
+		if len(cookies[a].Path) != len(cookies[b].Path) {
+			return len(cookies[a].Path) > len(cookies[b].Path)
+		}
+		return cookies[a].Name < cookies[b].Name
+	})
+}
+
+// defaultCookiePath Set-Cookie 没有指定 Path 时的默认值，见 RFC 6265 5.1.4
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
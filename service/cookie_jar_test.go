@@ -0,0 +1,75 @@
+package service
+
+import (
+	"github.com/asynccnu/be-ccnu/domain"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRecordingJar(t *testing.T) {
+	j := newRecordingJar()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	j.SetCookies(mustParse(t, "https://account.ccnu.edu.cn/cas/login"), []*http.Cookie{
+		{Name: "JSESSIONID", Value: "cas-1", Path: "/cas"},
+		{Name: "CASTGC", Value: "TGT-1", Path: "/cas", Expires: expires},
+		// 没有 Path 时按请求路径取默认值
+		{Name: "lang", Value: "zh"},
+	})
+	j.SetCookies(mustParse(t, "https://xk.ccnu.edu.cn/jwglxt/xtgl/login_slogin.html"), []*http.Cookie{
+		{Name: "JSESSIONID", Value: "xk-1", Path: "/jwglxt"},
+		{Name: "route", Value: "r1", Domain: ".ccnu.edu.cn", Path: "/", MaxAge: 60},
+	})
+
+	got := j.Snapshot(mustParse(t, "https://account.ccnu.edu.cn/cas/login"))
+	want := []domain.Cookie{
+		{Name: "CASTGC", Value: "TGT-1", Domain: "account.ccnu.edu.cn", Path: "/cas", ExpireAt: expires},
+		{Name: "JSESSIONID", Value: "cas-1", Domain: "account.ccnu.edu.cn", Path: "/cas"},
+		{Name: "lang", Value: "zh", Domain: "account.ccnu.edu.cn", Path: "/cas"},
+	}
+	// route 设置在 .ccnu.edu.cn 上，CAS 也会带上，过期时间是按 MaxAge 算出来的，单独检查
+	if len(got) != 4 || got[3].Name != "route" || got[3].Domain != "ccnu.edu.cn" || got[3].ExpireAt.IsZero() {
+		t.Fatalf("CAS 的 Cookie 有误: %+v", got)
+	}
+	if !reflect.DeepEqual(got[:3], want) {
+		t.Fatalf("CAS 的 Cookie 有误:\n got %+v\nwant %+v", got[:3], want)
+	}
+
+	// 不同 Path 下同名的 JSESSIONID 只返回请求时会带上的那个
+	got = j.Snapshot(mustParse(t, "https://xk.ccnu.edu.cn/jwglxt/cjcx/cjcx_cxDgXscj.html"))
+	if len(got) != 2 || got[0].Value != "xk-1" || got[1].Name != "route" {
+		t.Fatalf("教务系统的 Cookie 有误: %+v", got)
+	}
+	if got = j.HostCookies("xk.ccnu.edu.cn"); len(got) != 2 {
+		t.Fatalf("HostCookies 不区分 Path: %+v", got)
+	}
+
+	// 上游删除 Cookie 后不再返回
+	j.SetCookies(mustParse(t, "https://account.ccnu.edu.cn/cas/logout"), []*http.Cookie{
+		{Name: "CASTGC", Value: "", Path: "/cas", MaxAge: -1},
+	})
+	for _, c := range j.HostCookies("account.ccnu.edu.cn") {
+		if c.Name == "CASTGC" {
+			t.Fatalf("删除的 Cookie 不应该返回: %+v", c)
+		}
+	}
+}
+
+func TestDefaultCookiePath(t *testing.T) {
+	for path, want := range map[string]string{"": "/", "/": "/", "/cas": "/", "/cas/login": "/cas", "/a/b/c": "/a/b"} {
+		if got := defaultCookiePath(path); got != want {
+			t.Errorf("%q: got %q, want %q", path, got, want)
+		}
+	}
+}
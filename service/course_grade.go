@@ -2,8 +2,8 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
 	"github.com/asynccnu/be-ccnu/pkg/logger"
 	"github.com/ecodeclub/ekit/slice"
 	"io"
@@ -34,96 +34,101 @@ type GradeItem struct {
 }
 
 func (c *ccnuService) GetSelfGradeList(ctx context.Context, studentId, password, year, term string) ([]domain.Grade, error) {
-	client := c.getClientFromContext(ctx)
-	if client == nil {
+	var gl GradeList
+	err := c.doXK(ctx, studentId, password, func(sess *session) error {
 		var er error
-		client, er = c.xkLoginClient(ctx, studentId, password) // 登录，直接
-		if er != nil {
-			return nil, er
-		}
-	}
-	var termMap = map[string]string{"1": "3", "2": "12", "3": "16"} // 学期参数
-	if year == "0" {
-		year = ""
+		gl, er = c.queryGradeList(ctx, sess, year, term)
+		return er
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	res := slice.Map(gl.Items, func(idx int, src GradeItem) domain.Grade {
+		credit, _ := strconv.ParseFloat(src.Xf, 10)
+		total, _ := strconv.ParseFloat(src.Cj, 10)
+		return domain.Grade{
+			Course: domain.Course{
+				CourseId: src.Kch,
+				Name:     src.Kcmc,
+				Teacher:  src.Jsxm,
+				Class:    src.Jxbmc,
+				School:   src.Kkbmmc,
+				Property: src.Kcxzmc,
+				Credit:   credit,
+			},
+			Total: total,
+			Year:  src.Xnm,
+			Term:  src.Xqmmc,
+			JxbId: src.JxbId,
+		}
+	})
+	return res, nil
+}
+
+func (c *ccnuService) queryGradeList(ctx context.Context, sess *session, year, term string) (GradeList, error) {
+	xnm, xqm := c.calendar.XKParams(year, term)
 	formData := url.Values{}
-	formData.Set("xnm", year)          // 学年名
-	formData.Set("xqm", termMap[term]) // 学期名
-	formData.Set("kcbj", "")           //
+	formData.Set("xnm", xnm) // 学年名
+	formData.Set("xqm", xqm) // 学期名
+	formData.Set("kcbj", "")
 	formData.Set("_search", "false")
-	formData.Set("nd", string(time.Now().UnixNano()))
+	formData.Set("nd", strconv.FormatInt(time.Now().UnixNano(), 10))
 	formData.Set("queryModel.showCount", "1000")
 	formData.Set("queryModel.currentPage", "1")
 	formData.Set("queryModel.sortName", "")
 	formData.Set("queryModel.sortOrder", "asc")
 	formData.Set("time", "5")
 
-	requestUrl := "https://xk.ccnu.edu.cn/jwglxt/cjcx/cjcx_cxXsgrcj.html?doType=query&gnmkdm=N305005"
-	req, err := http.NewRequest("POST", requestUrl, strings.NewReader(formData.Encode()))
+	requestUrl := sess.system.cfg.Grade.URL(url.Values{"doType": {"query"}})
+	req, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "POST", requestUrl, strings.NewReader(formData.Encode()))
 	if err != nil {
-		return nil, err
+		return GradeList{}, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
 	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8,en-GB;q=0.7,en-US;q=0.6")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/123.0.0.0 Safari/537.36 Edg/123.0.0.0")
-	req.Header.Set("Referer", "http://xk.ccnu.edu.cn/jwglxt/cjcx/cjcx_cxDgXscj.html?gnmkdm=N305005&layout=default")
-	req.Header.Set("Origin", "http://xk.ccnu.edu.cn")
+	req.Header.Set("Referer", sess.system.cfg.GradePage.URL(url.Values{"layout": {"default"}}))
+	req.Header.Set("Origin", sess.system.cfg.Grade.Origin())
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
 
-	resp, err := client.Do(req)
+	resp, err := sess.client.Do(req)
 	if err != nil {
-		return nil, err
+		return GradeList{}, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return GradeList{}, err
+	}
+	if err = checkXKResponse(resp, body); err != nil {
+		return GradeList{}, err
 	}
 	var gl GradeList
-	err = json.Unmarshal(body, &gl)
+	err = decodeXKJSON(body, &gl)
 	if err != nil {
-		return nil, err
+		return GradeList{}, err
 	}
-
-	res := slice.Map(gl.Items, func(idx int, src GradeItem) domain.Grade {
-		credit, _ := strconv.ParseFloat(src.Xf, 10)
-		total, _ := strconv.ParseFloat(src.Cj, 10)
-		return domain.Grade{
-			Course: domain.Course{
-				CourseId: src.Kch,
-				Name:     src.Kcmc,
-				Teacher:  src.Jsxm,
-				Class:    src.Jxbmc,
-				School:   src.Kkbmmc,
-				Property: src.Kcxzmc,
-				Credit:   credit,
-			},
-			Total: total,
-			Year:  src.Xnm,
-			Term:  src.Xqmmc,
-			JxbId: src.JxbId,
-		}
-	})
-	return res, nil
+	return gl, nil
 }
 
 func (c *ccnuService) GetDetailOfGradeList(ctx context.Context, studentId string, password string, year string, term string) ([]domain.Grade, error) {
-	// 优化为一次登录，因为这里有很多请求，用一个client就行
-	client, err := c.xkLoginClient(ctx, studentId, password)
-	if err != nil {
-		return nil, err
-	}
-	ctx = c.addClientToContext(ctx, client)
+	// 登录后的会话会被缓存，后面这些请求都复用同一个会话
 	gradeList, err := c.GetSelfGradeList(ctx, studentId, password, year, term) // A
 	if err != nil {
 		return nil, err
 	}
 	// 聚成绩的详细内容
 	for i, grade := range gradeList {
-		detail, er := c.getGradeDetail(ctx, studentId, password, grade.Year, grade.Term, grade.JxbId) // B
+		var detail xkGradeListRespBody
+		er := c.doXK(ctx, studentId, password, func(sess *session) error {
+			var e error
+			detail, e = c.getGradeDetail(ctx, sess, grade.Year, grade.Term, grade.JxbId) // B
+			return e
+		})
 		if er != nil {
 			return nil, er
 		}
@@ -151,20 +156,12 @@ type xkGradeListRespBody struct {
 	Items []xkGradeListItem `json:"items"`
 }
 
-func (c *ccnuService) getGradeDetail(ctx context.Context, studentId, password string, year string, term string, jxbId string) (xkGradeListRespBody, error) {
-	client := c.getClientFromContext(ctx)
-	if client == nil {
-		var er error
-		client, er = c.xkLoginClient(ctx, studentId, password) // 登录，直接
-		if er != nil {
-			return xkGradeListRespBody{}, er
-		}
-	}
-	var termMap = map[string]string{"1": "3", "2": "12", "3": "16"} // 学期参数
+func (c *ccnuService) getGradeDetail(ctx context.Context, sess *session, year string, term string, jxbId string) (xkGradeListRespBody, error) {
+	xnm, xqm := c.calendar.XKParams(year, term)
 	// 准备请求参数
 	formData := url.Values{}
-	formData.Set("xnm", year)          // 学年, 留空
-	formData.Set("xqm", termMap[term]) // 学期, 留空
+	formData.Set("xnm", xnm) // 学年, 留空
+	formData.Set("xqm", xqm) // 学期, 留空
 	formData.Set("jxb_id", jxbId)
 	formData.Set("_search", "false")
 	formData.Set("nd", strconv.FormatInt(time.Now().Unix(), 10))
@@ -175,8 +172,8 @@ func (c *ccnuService) getGradeDetail(ctx context.Context, studentId, password st
 	formData.Set("time", "3")
 
 	// 请求URL
-	requestUrl := "https://xk.ccnu.edu.cn/jwglxt/cjcx/cjcx_cxXsXmcjList.html?gnmkdm=N305007"
-	req, err := http.NewRequest("POST", requestUrl, strings.NewReader(formData.Encode()))
+	requestUrl := sess.system.cfg.GradeDetail.URL(nil)
+	req, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "POST", requestUrl, strings.NewReader(formData.Encode()))
 	if err != nil {
 		return xkGradeListRespBody{}, err
 	}
@@ -186,8 +183,8 @@ func (c *ccnuService) getGradeDetail(ctx context.Context, studentId, password st
 	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
 	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8,en-GB;q=0.7,en-US;q=0.6")
 	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Origin", "https://xk.ccnu.edu.cn")
-	req.Header.Set("Referer", "https://xk.ccnu.edu.cn/jwglxt/cjcx/cjcx_cxDgXsxmcj.html?gnmkdm=N305007&layout=default")
+	req.Header.Set("Origin", sess.system.cfg.GradeDetail.Origin())
+	req.Header.Set("Referer", sess.system.cfg.GradeDetailPage.URL(url.Values{"layout": {"default"}}))
 	req.Header.Set("Sec-Fetch-Dest", "empty")
 	req.Header.Set("Sec-Fetch-Mode", "cors")
 	req.Header.Set("Sec-Fetch-Site", "same-origin")
@@ -195,7 +192,7 @@ func (c *ccnuService) getGradeDetail(ctx context.Context, studentId, password st
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
 
 	// 发送请求
-	resp, err := client.Do(req)
+	resp, err := sess.client.Do(req)
 	if err != nil {
 		return xkGradeListRespBody{}, err
 	}
@@ -206,8 +203,11 @@ func (c *ccnuService) getGradeDetail(ctx context.Context, studentId, password st
 	if err != nil {
 		return xkGradeListRespBody{}, err
 	}
+	if err = checkXKResponse(resp, body); err != nil {
+		return xkGradeListRespBody{}, err
+	}
 	var gradeList xkGradeListRespBody // 此处定义合适的数据结构来解析JSON响应
-	err = json.Unmarshal(body, &gradeList)
+	err = decodeXKJSON(body, &gradeList)
 	if err != nil {
 		return xkGradeListRespBody{}, err
 	}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/pkg/cryptox"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/repository"
+)
+
+// CredentialVault 加密保存学生密码，供用户不在线时后台刷新数据使用
+type CredentialVault struct {
+	repo    repository.CredentialRepository
+	keyring *cryptox.Keyring
+	l       logger.Logger
+}
+
+func NewCredentialVault(repo repository.CredentialRepository, keyring *cryptox.Keyring, l logger.Logger) *CredentialVault {
+	return &CredentialVault{
+		repo:    repo,
+		keyring: keyring,
+		l:       l,
+	}
+}
+
+func (v *CredentialVault) Save(ctx context.Context, studentId string, password string) error {
+	// 学号作为附加数据参与认证，防止密文被挪到别的学号下面使用
+	keyId, ciphertext, err := v.keyring.Encrypt([]byte(password), []byte(studentId))
+	if err != nil {
+		return err
+	}
+	return v.repo.Upsert(ctx, repository.Credential{
+		StudentId:  studentId,
+		KeyId:      keyId,
+		Ciphertext: ciphertext,
+	})
+}
+
+func (v *CredentialVault) Password(ctx context.Context, studentId string) (string, error) {
+	c, err := v.repo.FindByStudentId(ctx, studentId)
+	if err != nil {
+		return "", err
+	}
+	password, err := v.keyring.Decrypt(c.KeyId, c.Ciphertext, []byte(studentId))
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+func (v *CredentialVault) Delete(ctx context.Context, studentId string) error {
+	return v.repo.Delete(ctx, studentId)
+}
+
+// ReEncrypt 用当前的主密钥重新加密所有用旧密钥加密的密码，返回重新加密的条数。
+// 解密失败的记录（比如旧密钥已经从配置中删掉）会被跳过并记录日志
+func (v *CredentialVault) ReEncrypt(ctx context.Context) (int, error) {
+	const batchSize = 100
+	var (
+		cnt   int
+		after string
+	)
+	for {
+		creds, err := v.repo.FindNotEncryptedBy(ctx, v.keyring.Primary(), after, batchSize)
+		if err != nil {
+			return cnt, err
+		}
+		for _, c := range creds {
+			after = c.StudentId
+			password, er := v.keyring.Decrypt(c.KeyId, c.Ciphertext, []byte(c.StudentId))
+			if er != nil {
+				v.l.Warn("重新加密时解密失败", logger.String("studentId", c.StudentId),
+					logger.String("keyId", c.KeyId), logger.Error(er))
+				continue
+			}
+			if er = v.Save(ctx, c.StudentId, string(password)); er != nil {
+				return cnt, er
+			}
+			cnt++
+		}
+		if len(creds) < batchSize {
+			return cnt, nil
+		}
+	}
+}
+
+func (c *ccnuService) SaveCredential(ctx context.Context, studentId string, password string) error {
+	if c.vault == nil {
+		return errVaultDisabled
+	}
+	// 空密码会让 xkSession 用回保存的密码，校验能通过，但保存下来之后每次后台登录都会失败
+	if password == "" {
+		return ccnuv1.ErrorInvalidSidOrPwd("缺少密码")
+	}
+	// 先确认密码是对的再保存
+	if _, err := c.xkSession(ctx, studentId, password); err != nil {
+		return err
+	}
+	return c.vault.Save(ctx, studentId, password)
+}
+
+func (c *ccnuService) DeleteCredential(ctx context.Context, studentId string) error {
+	if c.vault == nil {
+		return errVaultDisabled
+	}
+	return c.vault.Delete(ctx, studentId)
+}
+
+func (c *ccnuService) ReEncryptCredentials(ctx context.Context) (int, error) {
+	if c.vault == nil {
+		return 0, errVaultDisabled
+	}
+	return c.vault.ReEncrypt(ctx)
+}
+
+type trustedCallerKey struct{}
+
+// WithTrustedCaller 标记调用方已经通过鉴权并且在白名单里，只有这样的调用方才能只传学号使用保存的密码
+func WithTrustedCaller(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trustedCallerKey{}, true)
+}
+
+func isTrustedCaller(ctx context.Context) bool {
+	trusted, _ := ctx.Value(trustedCallerKey{}).(bool)
+	return trusted
+}
+
+// resolvePassword 调用方只传了学号时，使用保存的密码。
+// 否则任何人只要知道学号就能以这个学生的身份查询，所以只对受信任的调用方开放
+func (c *ccnuService) resolvePassword(ctx context.Context, studentId string, password string) (string, bool, error) {
+	if password != "" {
+		return password, false, nil
+	}
+	if c.vault == nil || !isTrustedCaller(ctx) {
+		return "", false, ccnuv1.ErrorInvalidSidOrPwd("缺少密码")
+	}
+	password, err := c.vault.Password(ctx, studentId)
+	if errors.Is(err, repository.ErrCredentialNotFound) {
+		return "", false, ccnuv1.ErrorInvalidSidOrPwd("缺少密码，且没有保存该学号的密码")
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return password, true, nil
+}
+
+// forgetStaleCredential 保存的密码已经登录不上了（比如学生改了密码），删掉它，避免反复尝试把账号锁住
+func (c *ccnuService) forgetStaleCredential(ctx context.Context, studentId string, err error) {
+	if !ccnuv1.IsInvalidSidOrPwd(err) {
+		return
+	}
+	if er := c.vault.Delete(ctx, studentId); er != nil {
+		c.l.Error("删除失效的密码失败", logger.String("studentId", studentId), logger.Error(er))
+	}
+}
+
+var errVaultDisabled = errors.New("未启用密码保存")
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/pkg/cryptox"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/repository"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestCredentialVaultReEncrypt(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryCredentialRepository()
+	l := logger.NewNopLogger()
+	k1 := []byte("0123456789abcdef0123456789abcdef")
+	k2 := []byte("fedcba9876543210fedcba9876543210")
+
+	before, err := cryptox.NewKeyring("k1", map[string][]byte{"k1": k1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"2023214001", "2023214002"} {
+		if err = service.NewCredentialVault(repo, before, l).Save(ctx, id, "pwd-"+id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 用已经删掉的密钥加密的记录解不开，跳过
+	lost, _ := cryptox.NewKeyring("k0", map[string][]byte{"k0": k2})
+	if err = service.NewCredentialVault(repo, lost, l).Save(ctx, "2023214003", "pwd"); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := cryptox.NewKeyring("k2", map[string][]byte{"k1": k1, "k2": k2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := service.NewCredentialVault(repo, after, l)
+	cnt, err := vault.ReEncrypt(ctx)
+	if err != nil || cnt != 2 {
+		t.Fatalf("应该重新加密 2 条: %d, %v", cnt, err)
+	}
+	for _, id := range []string{"2023214001", "2023214002"} {
+		c, err := repo.FindByStudentId(ctx, id)
+		if err != nil || c.KeyId != "k2" {
+			t.Fatalf("%s 应该改用 k2 加密: %+v, %v", id, c, err)
+		}
+		if password, err := vault.Password(ctx, id); err != nil || password != "pwd-"+id {
+			t.Fatalf("重新加密后密码有误: %q, %v", password, err)
+		}
+	}
+	if cnt, err = vault.ReEncrypt(ctx); err != nil || cnt != 0 {
+		t.Fatalf("已经是新密钥的记录不用再加密: %d, %v", cnt, err)
+	}
+}
+
+func TestStoredPasswordOnlyForTrustedCallers(t *testing.T) {
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	trusted := service.WithTrustedCaller(context.Background())
+	if err := svc.SaveCredential(trusted, undergraduateId, ""); !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("不能保存空密码: %v", err)
+	}
+	if err := svc.SaveCredential(trusted, undergraduateId, password); err != nil {
+		t.Fatal(err)
+	}
+
+	// 匿名调用方不传密码，不能用上保存的密码
+	hits := fake.Hits(fakeccnu.RouteCASLogin)
+	if ok, err := svc.Login(context.Background(), undergraduateId, ""); ok || !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("匿名调用方不传密码应该被拒绝: %v, %v", ok, err)
+	}
+	if _, err := svc.GetTimetable(context.Background(), undergraduateId, "", "2023", "1"); !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("匿名调用方不传密码应该被拒绝: %v", err)
+	}
+	if got := fake.Hits(fakeccnu.RouteCASLogin); got != hits {
+		t.Fatalf("被拒绝的请求不应该访问 CAS，多请求了 %d 次", got-hits)
+	}
+
+	if _, err := svc.GetTimetable(trusted, undergraduateId, "", "2023", "1"); err != nil {
+		t.Fatalf("受信任的调用方可以只传学号: %v", err)
+	}
+	if err := svc.DeleteCredential(trusted, undergraduateId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetTimetable(trusted, "2023214002", "", "2023", "1"); !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("没有保存密码的学号应该被拒绝: %v", err)
+	}
+}
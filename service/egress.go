@@ -0,0 +1,21 @@
+package service
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/ecodeclub/ekit/slice"
+)
+
+func (c *ccnuService) ListEgressStats(ctx context.Context) []domain.EgressStats {
+	return slice.Map(c.egress.Stats(), func(idx int, src httpx.EgressStats) domain.EgressStats {
+		return domain.EgressStats{
+			Name:         src.Name,
+			Healthy:      src.Healthy,
+			EjectedUntil: src.EjectedUntil,
+			Requests:     src.Requests,
+			Failures:     src.Failures,
+			Sessions:     src.Sessions,
+		}
+	})
+}
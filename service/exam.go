@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OriginalExamList struct {
+	Items []OriginalExamItem `json:"items"`
+}
+
+// OriginalExamItem 考试安排中的一场考试
+type OriginalExamItem struct {
+	Kch    string `json:"kch"`    // 课程号
+	Kcmc   string `json:"kcmc"`   // 课程名称
+	Ksmc   string `json:"ksmc"`   // 考试名称，如 2023-2024学年第一学期期末考试
+	Kssj   string `json:"kssj"`   // 考试时间，如 2024-01-08(09:00-11:00)
+	Cdxqmc string `json:"cdxqmc"` // 考场所在校区
+	Cdmc   string `json:"cdmc"`   // 考场
+	Zwh    string `json:"zwh"`    // 座位号
+}
+
+// GetExams 个人考试安排
+func (c *ccnuService) GetExams(ctx context.Context, studentId, password, year, term string) ([]domain.Exam, error) {
+	var data OriginalExamList
+	err := c.doXK(ctx, studentId, password, func(sess *session) error {
+		var er error
+		data, er = c.queryExams(ctx, sess, year, term)
+		return er
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Exam, 0, len(data.Items))
+	for _, item := range data.Items {
+		e, err := convertExamItem(item)
+		if err != nil {
+			// 不能因为一场考试的时间认不出来就让学生看不到所有考试，这场考试按还没有安排时间返回
+			c.l.Warn("无法识别的考试时间", logger.String("kch", item.Kch), logger.String("kssj", item.Kssj), logger.Error(err))
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+func (c *ccnuService) queryExams(ctx context.Context, sess *session, year, term string) (OriginalExamList, error) {
+	xnm, xqm := c.calendar.XKParams(year, term)
+	formData := url.Values{}
+	formData.Set("xnm", xnm)
+	formData.Set("xqm", xqm)
+	formData.Set("ksmcdmb_id", "")
+	formData.Set("kch", "")
+	formData.Set("kc", "")
+	formData.Set("ksrq", "")
+	formData.Set("_search", "false")
+	formData.Set("nd", strconv.FormatInt(time.Now().UnixNano(), 10))
+	formData.Set("queryModel.showCount", "100")
+	formData.Set("queryModel.currentPage", "1")
+	formData.Set("queryModel.sortName", "")
+	formData.Set("queryModel.sortOrder", "asc")
+	formData.Set("time", "0")
+
+	requestUrl := sess.system.cfg.Exam.URL(url.Values{"doType": {"query"}})
+	req, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "POST", requestUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return OriginalExamList{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+	req.Header.Set("Origin", sess.system.cfg.Exam.Origin())
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+
+	resp, err := sess.client.Do(req)
+	if err != nil {
+		return OriginalExamList{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return OriginalExamList{}, err
+	}
+	if err = checkXKResponse(resp, body); err != nil {
+		return OriginalExamList{}, err
+	}
+	var data OriginalExamList
+	err = decodeXKJSON(body, &data)
+	return data, err
+}
+
+// convertExamItem 考试时间认不出来时返回错误，其它字段照常填好，时间为零值
+func convertExamItem(src OriginalExamItem) (domain.Exam, error) {
+	start, end, err := parseExamTime(src.Kssj)
+	return domain.Exam{
+		CourseId: src.Kch,
+		Name:     src.Kcmc,
+		ExamName: src.Ksmc,
+		Type:     examType(src.Ksmc),
+		Start:    start,
+		End:      end,
+		Campus:   src.Cdxqmc,
+		Room:     src.Cdmc,
+		Seat:     src.Zwh,
+	}, err
+}
+
+// parseExamTime 解析 2024-01-08(09:00-11:00) 这样的考试时间，按学校所在的时区。还没有安排时间时返回零值
+func parseExamTime(s string) (time.Time, time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, time.Time{}, nil
+	}
+	date, clocks, ok := strings.Cut(s, "(")
+	if !ok {
+		return time.Time{}, time.Time{}, strconv.ErrSyntax
+	}
+	from, to, ok := strings.Cut(strings.TrimSuffix(clocks, ")"), "-")
+	if !ok {
+		return time.Time{}, time.Time{}, strconv.ErrSyntax
+	}
+	start, err := time.ParseInLocation("2006-01-02 15:04", date+" "+from, shanghai)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.ParseInLocation("2006-01-02 15:04", date+" "+to, shanghai)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, end, nil
+}
+
+// examType 教务系统没有单独的考试类型字段，只能从考试名称里判断
+func examType(name string) domain.ExamType {
+	switch {
+	case strings.Contains(name, "补考"):
+		return domain.MakeupExam
+	case strings.Contains(name, "重修"):
+		return domain.RetakeExam
+	}
+	return domain.FinalExam
+}
@@ -0,0 +1,47 @@
+package service_test
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+	"time"
+)
+
+func TestGetExams(t *testing.T) {
+	_, svc := newTestService(t, fakeccnu.Account{
+		StudentId: undergraduateId,
+		Password:  password,
+		Exams: map[string][]service.OriginalExamItem{"2023-1": {
+			{Kch: "CS101", Kcmc: "数据结构", Ksmc: "2023-2024学年第一学期期末考试", Kssj: "2024-01-08(09:00-11:00)", Cdxqmc: "本部", Cdmc: "7101", Zwh: "12"},
+			{Kch: "MA101", Kcmc: "高等数学", Ksmc: "2023-2024学年第一学期补考", Kssj: ""},
+			{Kch: "EN101", Kcmc: "大学英语", Ksmc: "重修考试", Kssj: "待定"},
+			{Kch: "PH101", Kcmc: "大学物理", Ksmc: "期末考试", Kssj: "2024-01-10(14:30-1630)"},
+		}},
+	})
+	exams, err := svc.GetExams(context.Background(), undergraduateId, password, "2023", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exams) != 4 {
+		t.Fatalf("时间认不出来的考试也要返回，got %d 场", len(exams))
+	}
+	shanghai := time.FixedZone("CST", 8*60*60)
+	first := exams[0]
+	if !first.Start.Equal(time.Date(2024, 1, 8, 9, 0, 0, 0, shanghai)) || !first.End.Equal(time.Date(2024, 1, 8, 11, 0, 0, 0, shanghai)) {
+		t.Errorf("考试时间有误: %s - %s", first.Start, first.End)
+	}
+	if first.Type != domain.FinalExam || first.Room != "7101" || first.Seat != "12" {
+		t.Errorf("考试信息有误: %+v", first)
+	}
+	wantTypes := []domain.ExamType{domain.FinalExam, domain.MakeupExam, domain.RetakeExam, domain.FinalExam}
+	for i, e := range exams {
+		if e.Type != wantTypes[i] {
+			t.Errorf("%s 的考试类型有误: %v", e.Name, e.Type)
+		}
+		if i > 0 && (!e.Start.IsZero() || !e.End.IsZero()) {
+			t.Errorf("%s 的时间应该是零值: %s", e.Name, e.Start)
+		}
+	}
+}
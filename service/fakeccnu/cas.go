@@ -0,0 +1,219 @@
+package fakeccnu
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// captchaPath 验证码图片的地址
+const captchaPath = "/cas/captcha.jpg"
+
+// captchaImage 一张 1x1 的 GIF，内容无所谓，验证码固定为 CaptchaCode
+var captchaImage = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+// casSession 一个 CAS 登录页会话，lt 和 execution 每提交一次表单就会换一次
+type casSession struct {
+	lt        string
+	execution string
+	// captcha 这个会话的登录表单是否需要验证码
+	captcha bool
+}
+
+func (s *Server) handleCASLogin(w http.ResponseWriter, r *http.Request, _ string) {
+	switch r.Method {
+	case http.MethodGet:
+		s.casLoginPage(w, r)
+	case http.MethodPost:
+		s.casSubmit(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// casLoginPage 已经登录 CAS 且带了 service 参数时签发 ticket 并跳回 service，否则返回登录页
+func (s *Server) casLoginPage(w http.ResponseWriter, r *http.Request) {
+	if svc := r.URL.Query().Get("service"); svc != "" {
+		if ticket, ok := s.issueTicket(r); ok {
+			u, err := url.Parse(svc)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			q := u.Query()
+			q.Set("ticket", ticket)
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	id := ""
+	if c, err := r.Cookie("JSESSIONID"); err == nil && s.casSessions[c.Value] != nil {
+		id = c.Value
+	} else {
+		id = randomId("")
+		s.casSessions[id] = &casSession{}
+	}
+	cs := s.casSessions[id]
+	cs.renew()
+	page := loginPage(cs, "")
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: id, Path: "/cas", HttpOnly: true})
+	writeHTML(w, page)
+}
+
+func (s *Server) issueTicket(r *http.Request) (string, bool) {
+	c, err := r.Cookie("CASTGC")
+	if err != nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	studentId, ok := s.tgts[c.Value]
+	if !ok {
+		return "", false
+	}
+	ticket := randomId("ST-")
+	s.tickets[ticket] = studentId
+	return ticket, true
+}
+
+// casSubmit 处理登录表单。登录失败时返回带 id="msg" 错误提示的登录页，并且不下发任何 Cookie
+func (s *Server) casSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := ""
+	if _, after, ok := strings.Cut(r.URL.Path, ";jsessionid="); ok {
+		id = after
+	} else if c, err := r.Cookie("JSESSIONID"); err == nil {
+		id = c.Value
+	}
+	studentId := r.PostForm.Get("username")
+
+	s.mu.Lock()
+	cs, ok := s.casSessions[id]
+	if !ok || cs.lt == "" || r.PostForm.Get("lt") != cs.lt || r.PostForm.Get("execution") != cs.execution {
+		s.mu.Unlock()
+		writeHTML(w, `<html><body><p>登录页面已过期，请刷新后重新登录</p></body></html>`)
+		return
+	}
+	cs.renew()
+	needCaptcha := s.captchaAfter > 0 && s.badPasswords[studentId] >= s.captchaAfter
+	if needCaptcha && r.PostForm.Get("captcha") != CaptchaCode {
+		cs.captcha = true
+		page := loginPage(cs, "请输入正确的验证码")
+		s.mu.Unlock()
+		writeHTML(w, page)
+		return
+	}
+	a, ok := s.accounts[studentId]
+	if !ok || a.Password != r.PostForm.Get("password") {
+		s.badPasswords[studentId]++
+		if s.captchaAfter > 0 && s.badPasswords[studentId] >= s.captchaAfter {
+			cs.captcha = true
+		}
+		page := loginPage(cs, "您输入的用户名或密码有误")
+		s.mu.Unlock()
+		writeHTML(w, page)
+		return
+	}
+	s.badPasswords[studentId] = 0
+	if a.Locked {
+		page := loginPage(cs, "您的账号已被锁定，请联系管理员")
+		s.mu.Unlock()
+		writeHTML(w, page)
+		return
+	}
+	if a.PasswordExpired {
+		s.mu.Unlock()
+		writeHTML(w, `<html><body><h2>您的密码已过期，请修改密码后重新登录</h2>`+
+			`<form id="passwordManagementForm" method="post"><input type="password" name="password"/>`+
+			`<input type="password" name="confirmedPassword"/></form></body></html>`)
+		return
+	}
+	tgt := randomId("TGT-")
+	s.tgts[tgt] = studentId
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "CASTGC", Value: tgt, Path: "/cas", HttpOnly: true})
+	writeHTML(w, `<html><body><h2>登录成功</h2></body></html>`)
+}
+
+func (s *Server) handleCaptcha(w http.ResponseWriter, r *http.Request, _ string) {
+	w.Header().Set("Content-Type", "image/gif")
+	_, _ = w.Write(captchaImage)
+}
+
+// handleSSO 教务系统的单点登录入口，校验 ticket 后下发教务系统的 JSESSIONID
+func (s *Server) handleSSO(w http.ResponseWriter, r *http.Request, system string) {
+	ticket := r.URL.Query().Get("ticket")
+	s.mu.Lock()
+	studentId, ok := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	var id string
+	if ok {
+		id = randomId("")
+		s.xkSessions[id] = xkSession{studentId: studentId, system: system}
+	}
+	s.mu.Unlock()
+	if !ok {
+		// 没有有效的 ticket，跳回 CAS 登录
+		http.Redirect(w, r, s.upstream.CASLogin.URL(nil), http.StatusFound)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: id, Path: system, HttpOnly: true})
+	http.SetCookie(w, &http.Cookie{Name: "route", Value: randomId(""), Path: system})
+	writeHTML(w, `<html><head><title>教学管理信息服务平台</title></head><body></body></html>`)
+}
+
+// handleService 其它接入 CAS 的系统，校验 ticket 后在根路径下发 SESSION
+func (s *Server) handleService(w http.ResponseWriter, r *http.Request, _ string) {
+	ticket := r.URL.Query().Get("ticket")
+	s.mu.Lock()
+	_, ok := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	s.mu.Unlock()
+	if !ok {
+		http.Redirect(w, r, s.upstream.CASLogin.URL(url.Values{"service": {"http://" + r.Host + r.URL.Path}}), http.StatusFound)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: "SESSION", Value: randomId(""), Path: "/", HttpOnly: true})
+	writeHTML(w, `<html><body><h2>欢迎</h2></body></html>`)
+}
+
+func (cs *casSession) renew() {
+	cs.lt = randomId("LT-")
+	cs.execution = randomId("e1s")
+}
+
+func loginPage(cs *casSession, msg string) string {
+	var b strings.Builder
+	b.WriteString("<html><head><title>统一身份认证</title></head><body>\n")
+	b.WriteString("<form id=\"fm1\" method=\"post\">\n")
+	if msg != "" {
+		fmt.Fprintf(&b, "<div id=\"msg\" class=\"errors\">%s</div>\n", html.EscapeString(msg))
+	}
+	b.WriteString("<input id=\"username\" name=\"username\" type=\"text\" />\n")
+	b.WriteString("<input id=\"password\" name=\"password\" type=\"password\" />\n")
+	if cs.captcha {
+		b.WriteString("<input id=\"captcha\" name=\"captcha\" type=\"text\" />\n")
+		fmt.Fprintf(&b, "<img id=\"captchaImg\" src=\"%s\" />\n", captchaPath)
+	}
+	fmt.Fprintf(&b, "<input type=\"hidden\" name=\"lt\" value=\"%s\" />\n", cs.lt)
+	fmt.Fprintf(&b, "<input type=\"hidden\" name=\"execution\" value=\"%s\" />\n", cs.execution)
+	b.WriteString("<input type=\"hidden\" name=\"_eventId\" value=\"submit\" />\n")
+	b.WriteString("</form>\n</body></html>")
+	return b.String()
+}
+
+func writeHTML(w http.ResponseWriter, page string) {
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	_, _ = w.Write([]byte(page))
+}
@@ -0,0 +1,93 @@
+package fakeccnu
+
+import (
+	"net/http"
+	"time"
+)
+
+// 常见的上游异常页面
+const (
+	// MaintenancePage CAS 或教务系统维护时的页面
+	MaintenancePage = `<html><head><title>系统维护</title></head><body><h1>系统维护中，暂停服务</h1></body></html>`
+	// ErrorPage 教务系统出错时返回的 HTML 页面，查询接口本该返回 JSON
+	ErrorPage = `<html><head><title>错误提示</title></head><body><h5>系统运行异常，请联系管理员</h5></body></html>`
+	// EvaluationPage 没有完成评教时查询成绩返回的页面
+	EvaluationPage = `<html><head><title>提示信息</title></head><body><h5>您还没有完成本学期的学生评价，请先完成评教</h5></body></html>`
+	// PermissionDeniedPage 查询功能没有开放时的页面
+	PermissionDeniedPage = `<html><head><title>错误提示</title></head><body><h5>对不起，您无权访问此功能</h5></body></html>`
+)
+
+// Fault 注入到某个接口上的故障，几个效果按 Delay、ExpireSession、Status/Body 的顺序依次生效
+type Fault struct {
+	// Route 为空时对所有接口生效
+	Route Route
+	// Times 生效的次数，为 0 时一直生效
+	Times int
+	// Delay 延迟多久再响应，用于模拟上游超时
+	Delay time.Duration
+	// ExpireSession 处理请求前先让本次请求携带的教务系统会话失效
+	ExpireSession bool
+	// Status、Body 不为空时直接用它们响应，不再走正常的处理逻辑。只设置了 Body 时状态码为 200
+	Status int
+	Body   string
+}
+
+// Inject 注入故障，先注入的先匹配，每个请求最多命中一个故障
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults 清除所有故障
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// applyFault 应用命中的故障，已经写完响应时返回 true
+func (s *Server) applyFault(w http.ResponseWriter, r *http.Request, rt route) bool {
+	f, ok := s.takeFault(rt.name)
+	if !ok {
+		return false
+	}
+	sleep(r, f.Delay)
+	if f.ExpireSession {
+		if c, err := r.Cookie("JSESSIONID"); err == nil {
+			s.mu.Lock()
+			delete(s.xkSessions, c.Value)
+			s.mu.Unlock()
+		}
+	}
+	if f.Status == 0 && f.Body == "" {
+		return false
+	}
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(f.Body))
+	return true
+}
+
+func (s *Server) takeFault(name Route) (Fault, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if f.Route != "" && f.Route != name {
+			continue
+		}
+		res := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return res, true
+	}
+	return Fault{}, false
+}
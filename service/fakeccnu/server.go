@@ -0,0 +1,236 @@
+// Package fakeccnu 一个用 httptest 跑起来的假 CCNU 上游，模拟 CAS 登录、教务系统单点登录和课程、成绩查询接口，
+// 把 service.UpstreamConfig 指向它就可以在没有校园网和真实账号的情况下把 ccnuService 的各条路径跑一遍
+package fakeccnu
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/asynccnu/be-ccnu/service"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route 假上游的各个接口，用于注入故障和统计请求次数
+type Route string
+
+const (
+	RouteCASLogin    Route = "casLogin"
+	RouteCaptcha     Route = "captcha"
+	RouteSSO         Route = "sso"
+	RouteCourse      Route = "course"
+	RouteGrade       Route = "grade"
+	RouteGradeDetail Route = "gradeDetail"
+	RouteKeepAlive   Route = "keepAlive"
+	RouteTimetable   Route = "timetable"
+	RouteExam        Route = "exam"
+	RouteClassroom   Route = "classroom"
+	RouteStatic      Route = "static"
+	// RouteService 教务系统之外接入 CAS 的系统的登录入口
+	RouteService Route = "service"
+)
+
+// CaptchaCode 需要验证码时，唯一正确的验证码
+const CaptchaCode = "ab12"
+
+// Server 假的 CCNU 上游，CAS 和两套教务系统都挂在同一个地址上，路径和线上保持一致
+type Server struct {
+	srv      *httptest.Server
+	upstream service.UpstreamConfig
+	routes   map[string]route
+
+	mu       sync.Mutex
+	accounts map[string]*Account
+	// casSessions CAS 登录页的 JSESSIONID 到登录表单的映射
+	casSessions map[string]*casSession
+	// tgts CASTGC 到学号的映射
+	tgts map[string]string
+	// tickets 还没使用的 service ticket 到学号的映射
+	tickets map[string]string
+	// xkSessions 教务系统的 JSESSIONID 到会话的映射
+	xkSessions map[string]xkSession
+	// badPasswords 每个学号连续输错密码的次数
+	badPasswords map[string]int
+	captchaAfter int
+	faults       []*Fault
+	hits         map[Route]int
+	// classrooms 空闲教室，键见 SetFreeClassrooms
+	classrooms map[string][]service.OriginalClassroomItem
+}
+
+// route 一个路径对应的接口，system 是教务系统的路径前缀，比如 /jwglxt
+type route struct {
+	name    Route
+	system  string
+	handler func(w http.ResponseWriter, r *http.Request, system string)
+}
+
+// NewServer 启动假上游，用完需要调用 Close
+func NewServer(accounts ...Account) *Server {
+	s := &Server{
+		accounts:     make(map[string]*Account),
+		casSessions:  make(map[string]*casSession),
+		tgts:         make(map[string]string),
+		tickets:      make(map[string]string),
+		xkSessions:   make(map[string]xkSession),
+		badPasswords: make(map[string]int),
+		hits:         make(map[Route]int),
+		classrooms:   make(map[string][]service.OriginalClassroomItem),
+	}
+	for _, a := range accounts {
+		s.AddAccount(a)
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.upstream = rebase(service.DefaultUpstreamConfig(), s.srv.URL)
+	s.routes = map[string]route{
+		s.upstream.CASLogin.Path: {name: RouteCASLogin, handler: s.handleCASLogin},
+		captchaPath:              {name: RouteCaptcha, handler: s.handleCaptcha},
+	}
+	for _, cfg := range []service.ZFSystemConfig{s.upstream.Undergraduate, s.upstream.Graduate} {
+		system := systemPrefix(cfg.Course.Path)
+		s.routes[cfg.SSO.Path] = route{name: RouteSSO, system: system, handler: s.handleSSO}
+		s.routes[cfg.Course.Path] = route{name: RouteCourse, system: system, handler: s.handleCourse}
+		s.routes[cfg.Grade.Path] = route{name: RouteGrade, system: system, handler: s.handleGrade}
+		s.routes[cfg.GradeDetail.Path] = route{name: RouteGradeDetail, system: system, handler: s.handleGradeDetail}
+		s.routes[cfg.KeepAlive.Path] = route{name: RouteKeepAlive, system: system, handler: s.handleKeepAlive}
+		s.routes[cfg.Timetable.Path] = route{name: RouteTimetable, system: system, handler: s.handleTimetable}
+		s.routes[cfg.Exam.Path] = route{name: RouteExam, system: system, handler: s.handleExam}
+		s.routes[cfg.FreeClassroom.Path] = route{name: RouteClassroom, system: system, handler: s.handleFreeClassroom}
+		s.routes[cfg.Static.Path] = route{name: RouteStatic, system: system, handler: s.handleStatic}
+	}
+	for _, ep := range s.upstream.Services {
+		s.routes[ep.Path] = route{name: RouteService, handler: s.handleService}
+	}
+	return s
+}
+
+// URL 假上游的地址
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Upstream 指向假上游的上游配置，可以直接传给 service.NewCCNUService
+func (s *Server) Upstream() service.UpstreamConfig {
+	return s.upstream
+}
+
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// AddAccount 添加或者替换一个账号
+func (s *Server) AddAccount(a Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[a.StudentId] = &a
+}
+
+// SetFreeClassrooms 设置某个校区第 week 周星期 weekday 第 start 到 end 节的空闲教室
+func (s *Server) SetFreeClassrooms(campus string, week, weekday, start, end int, rooms []service.OriginalClassroomItem) {
+	var periods int64
+	for p := start; p <= end; p++ {
+		periods |= 1 << (p - 1)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.classrooms[fmt.Sprintf("%s-%d-%d-%d", campus, 1<<(week-1), weekday, periods)] = rooms
+}
+
+// RequireCaptchaAfter 连续输错 n 次密码后登录页开始要求验证码，n 为 0 时不要求验证码
+func (s *Server) RequireCaptchaAfter(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.captchaAfter = n
+}
+
+// ExpireSessions 让所有已登录的教务系统会话失效，模拟上游会话过期
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.xkSessions = make(map[string]xkSession)
+}
+
+// Hits 某个接口收到的请求次数
+func (s *Server) Hits(r Route) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[r]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// CAS 的登录表单会提交到 /cas/login;jsessionid=xxx
+	path, _, _ := strings.Cut(r.URL.Path, ";")
+	rt, ok := s.routes[path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	s.hits[rt.name]++
+	s.mu.Unlock()
+	if s.applyFault(w, r, rt) {
+		return
+	}
+	rt.handler(w, r, rt.system)
+}
+
+// rebase 把上游配置里的所有地址换成假上游的地址，CAS 和教务系统的路径保持不变
+func rebase(cfg service.UpstreamConfig, base string) service.UpstreamConfig {
+	u, _ := url.Parse(base)
+	ep := func(e *service.Endpoint) {
+		e.Scheme = u.Scheme
+		e.Host = u.Host
+	}
+	ep(&cfg.CASLogin)
+	for _, sys := range []*service.ZFSystemConfig{&cfg.Undergraduate, &cfg.Graduate} {
+		ep(&sys.SSO)
+		ep(&sys.Course)
+		ep(&sys.Grade)
+		ep(&sys.GradeDetail)
+		ep(&sys.GradePage)
+		ep(&sys.GradeDetailPage)
+		ep(&sys.KeepAlive)
+		ep(&sys.Timetable)
+		ep(&sys.Exam)
+		ep(&sys.FreeClassroom)
+		ep(&sys.Static)
+	}
+	// 其它系统原本在各自的域名下，路径可能和 CAS 冲突，加上系统名作为前缀
+	services := make(map[string]service.Endpoint, len(cfg.Services))
+	for name, e := range cfg.Services {
+		ep(&e)
+		e.Path = "/" + name + e.Path
+		services[name] = e
+	}
+	cfg.Services = services
+	return cfg
+}
+
+// systemPrefix 教务系统的路径前缀，/jwglxt/xkcx/xx.html 返回 /jwglxt
+func systemPrefix(path string) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return "/" + seg
+}
+
+func randomId(prefix string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// sleep 等待 d，请求被取消时提前返回
+func sleep(r *http.Request, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,154 @@
+package fakeccnu
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const (
+	testStudentId = "2023214001"
+	testPassword  = "pwd-123456"
+)
+
+var hiddenReg = regexp.MustCompile(`name="(lt|execution)" value="([^"]+)"`)
+
+func newTestClient(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar}
+}
+
+func get(t *testing.T, client *http.Client, u string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+// casLogin 按真实 CAS 的流程提交登录表单，返回提交后的页面
+func casLogin(t *testing.T, s *Server, client *http.Client, studentId, password string) string {
+	t.Helper()
+	loginURL := s.Upstream().CASLogin.URL(nil)
+	_, page := get(t, client, loginURL)
+	form := url.Values{"username": {studentId}, "password": {password}, "_eventId": {"submit"}}
+	for _, m := range hiddenReg.FindAllStringSubmatch(page, -1) {
+		form.Set(m[1], m[2])
+	}
+	resp, err := client.PostForm(loginURL, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func hasCookie(client *http.Client, u, name string) bool {
+	parsed, _ := url.Parse(u)
+	for _, c := range client.Jar.Cookies(parsed) {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCASLogin(t *testing.T) {
+	s := NewServer(
+		Account{StudentId: testStudentId, Password: testPassword},
+		Account{StudentId: "2023214002", Password: testPassword, Locked: true},
+	)
+	defer s.Close()
+	loginURL := s.Upstream().CASLogin.URL(nil)
+
+	client := newTestClient(t)
+	if page := casLogin(t, s, client, testStudentId, "wrong"); !strings.Contains(page, `id="msg"`) || hasCookie(client, loginURL, "CASTGC") {
+		t.Fatalf("密码错误时应该返回错误提示且不下发 CASTGC:\n%s", page)
+	}
+	if page := casLogin(t, s, newTestClient(t), "2023214002", testPassword); !strings.Contains(page, "锁定") {
+		t.Fatalf("锁定的账号应该提示已锁定:\n%s", page)
+	}
+	casLogin(t, s, client, testStudentId, testPassword)
+	if !hasCookie(client, loginURL, "CASTGC") {
+		t.Fatal("登录成功后应该下发 CASTGC")
+	}
+
+	// 过期的表单不能提交
+	resp, err := client.PostForm(loginURL, url.Values{"username": {testStudentId}, "password": {testPassword}, "lt": {"LT-old"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "已过期") {
+		t.Fatalf("过期的表单应该被拒绝:\n%s", body)
+	}
+}
+
+func TestSSOAndQuery(t *testing.T) {
+	s := NewServer(Account{StudentId: testStudentId, Password: testPassword})
+	defer s.Close()
+	cfg := s.Upstream().Undergraduate
+	client := newTestClient(t)
+
+	// 没有登录时查询会跳回 CAS 登录页
+	if _, page := get(t, client, cfg.KeepAlive.URL(nil)); !strings.Contains(page, `name="lt"`) {
+		t.Fatalf("没有会话时应该跳回 CAS 登录页:\n%s", page)
+	}
+
+	casLogin(t, s, client, testStudentId, testPassword)
+	get(t, client, s.Upstream().CASLogin.URL(url.Values{"service": {cfg.SSO.URL(nil)}}))
+	if _, page := get(t, client, cfg.KeepAlive.URL(nil)); !strings.Contains(page, testStudentId) {
+		t.Fatalf("单点登录后应该能访问教务系统:\n%s", page)
+	}
+	// 本科生的会话在研究生系统里无效
+	if _, page := get(t, client, s.Upstream().Graduate.KeepAlive.URL(nil)); !strings.Contains(page, `name="lt"`) {
+		t.Fatal("会话只在登录的那套系统里有效")
+	}
+
+	s.ExpireSessions()
+	if _, page := get(t, client, cfg.KeepAlive.URL(nil)); !strings.Contains(page, `name="lt"`) {
+		t.Fatal("ExpireSessions 之后会话应该失效")
+	}
+	if got := s.Hits(RouteSSO); got != 1 {
+		t.Fatalf("单点登录应该只请求了一次，实际 %d 次", got)
+	}
+}
+
+func TestInjectFault(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	static := s.Upstream().Undergraduate.Static.URL(nil)
+	client := newTestClient(t)
+
+	s.Inject(Fault{Route: RouteStatic, Times: 2, Status: http.StatusServiceUnavailable, Body: MaintenancePage})
+	for i := 0; i < 2; i++ {
+		if resp, page := get(t, client, static); resp.StatusCode != http.StatusServiceUnavailable || page != MaintenancePage {
+			t.Fatalf("第 %d 次应该命中故障: %d", i+1, resp.StatusCode)
+		}
+	}
+	if resp, _ := get(t, client, static); resp.StatusCode != http.StatusOK {
+		t.Fatalf("故障次数用完后应该恢复: %d", resp.StatusCode)
+	}
+
+	// 只对指定的接口生效
+	s.Inject(Fault{Route: RouteCourse, Status: http.StatusBadGateway})
+	if resp, _ := get(t, client, static); resp.StatusCode != http.StatusOK {
+		t.Fatalf("其它接口不应该受影响: %d", resp.StatusCode)
+	}
+	s.ClearFaults()
+	if got := s.Hits(RouteStatic); got != 4 {
+		t.Fatalf("请求次数有误: %d", got)
+	}
+}
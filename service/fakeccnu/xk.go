@@ -0,0 +1,171 @@
+package fakeccnu
+
+import (
+	"encoding/json"
+	"github.com/asynccnu/be-ccnu/service"
+	"html"
+	"net/http"
+)
+
+// Account 假上游里的一个账号和它的课程、成绩数据
+type Account struct {
+	StudentId string
+	Password  string
+	// Locked 账号已被锁定，PasswordExpired 密码已过期，密码正确时也会登录失败
+	Locked          bool
+	PasswordExpired bool
+
+	Courses []service.OriginalCourseItem
+	Grades  []service.GradeItem
+	// GradeDetails 按教学班 id（jxb_id）存放的成绩明细，顺序为平时、期末、总评
+	GradeDetails map[string][]GradeDetailItem
+	// Timetables 按 学年-学期 存放的课表，如 2023-1
+	Timetables map[string][]service.OriginalClassItem
+	// Exams 按 学年-学期 存放的考试安排
+	Exams map[string][]service.OriginalExamItem
+}
+
+// GradeDetailItem 成绩明细中的一项
+type GradeDetailItem struct {
+	Xmblmc string `json:"xmblmc"` // 成绩分项名称，如 平时(40%)
+	Xmcj   string `json:"xmcj"`   // 分项成绩
+}
+
+// xkSession 一个已登录教务系统的会话，只在登录的那套系统里有效
+type xkSession struct {
+	studentId string
+	system    string
+}
+
+// xqmTerms 教务系统的学期参数到学期名称的映射
+var xqmTerms = map[string]string{"3": "1", "12": "2", "16": "3"}
+
+// account 返回请求携带的教务系统会话对应的账号，会话无效时跳回 CAS 登录并返回 false
+func (s *Server) account(w http.ResponseWriter, r *http.Request, system string) (Account, bool) {
+	var a *Account
+	if c, err := r.Cookie("JSESSIONID"); err == nil {
+		s.mu.Lock()
+		if sess, ok := s.xkSessions[c.Value]; ok && sess.system == system {
+			a = s.accounts[sess.studentId]
+		}
+		s.mu.Unlock()
+	}
+	if a == nil {
+		http.Redirect(w, r, s.upstream.CASLogin.URL(nil), http.StatusFound)
+		return Account{}, false
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return Account{}, false
+	}
+	return *a, true
+}
+
+func (s *Server) handleCourse(w http.ResponseWriter, r *http.Request, system string) {
+	a, ok := s.account(w, r, system)
+	if !ok {
+		return
+	}
+	year, term := r.PostForm.Get("xnm"), xqmTerms[r.PostForm.Get("xqm")]
+	items := make([]service.OriginalCourseItem, 0, len(a.Courses))
+	for _, c := range a.Courses {
+		if (year == "" || c.Xnm == year) && (term == "" || c.Xqmc == term) {
+			items = append(items, c)
+		}
+	}
+	writeJSON(w, items)
+}
+
+func (s *Server) handleGrade(w http.ResponseWriter, r *http.Request, system string) {
+	a, ok := s.account(w, r, system)
+	if !ok {
+		return
+	}
+	year, term := r.PostForm.Get("xnm"), xqmTerms[r.PostForm.Get("xqm")]
+	items := make([]service.GradeItem, 0, len(a.Grades))
+	for _, g := range a.Grades {
+		if (year == "" || g.Xnm == year) && (term == "" || g.Xqmmc == term) {
+			items = append(items, g)
+		}
+	}
+	writeJSON(w, items)
+}
+
+func (s *Server) handleGradeDetail(w http.ResponseWriter, r *http.Request, system string) {
+	a, ok := s.account(w, r, system)
+	if !ok {
+		return
+	}
+	items := a.GradeDetails[r.PostForm.Get("jxb_id")]
+	if items == nil {
+		items = []GradeDetailItem{}
+	}
+	writeJSON(w, items)
+}
+
+func (s *Server) handleTimetable(w http.ResponseWriter, r *http.Request, system string) {
+	a, ok := s.account(w, r, system)
+	if !ok {
+		return
+	}
+	items := a.Timetables[r.PostForm.Get("xnm")+"-"+xqmTerms[r.PostForm.Get("xqm")]]
+	if items == nil {
+		items = []service.OriginalClassItem{}
+	}
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(service.OriginalTimetable{KbList: items})
+}
+
+func (s *Server) handleExam(w http.ResponseWriter, r *http.Request, system string) {
+	a, ok := s.account(w, r, system)
+	if !ok {
+		return
+	}
+	items := a.Exams[r.PostForm.Get("xnm")+"-"+xqmTerms[r.PostForm.Get("xqm")]]
+	if items == nil {
+		items = []service.OriginalExamItem{}
+	}
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(service.OriginalExamList{Items: items})
+}
+
+// handleFreeClassroom 空闲教室对所有账号都一样，按 校区-周次位图-星期-节次位图 查找
+func (s *Server) handleFreeClassroom(w http.ResponseWriter, r *http.Request, system string) {
+	if _, ok := s.account(w, r, system); !ok {
+		return
+	}
+	f := r.PostForm
+	s.mu.Lock()
+	items := s.classrooms[f.Get("xqh_id")+"-"+f.Get("zcd")+"-"+f.Get("xqj")+"-"+f.Get("jcd")]
+	s.mu.Unlock()
+	if items == nil {
+		items = []service.OriginalClassroomItem{}
+	}
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(service.OriginalClassroomList{Items: items})
+}
+
+// handleKeepAlive 教务系统的个人信息页，只用来顺延会话
+func (s *Server) handleKeepAlive(w http.ResponseWriter, r *http.Request, system string) {
+	a, ok := s.account(w, r, system)
+	if !ok {
+		return
+	}
+	writeHTML(w, "<html><body><p>"+html.EscapeString(a.StudentId)+"</p></body></html>")
+}
+
+// handleStatic 教务系统自己的登录页，不需要会话
+func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request, system string) {
+	writeHTML(w, "<html><head><title>教学管理信息服务平台</title></head><body></body></html>")
+}
+
+// writeJSON 按教务系统分页查询接口的格式返回数据
+func writeJSON[T any](w http.ResponseWriter, items []T) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"currentPage": 1,
+		"totalCount":  len(items),
+		"totalResult": len(items),
+		"items":       items,
+	})
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type OriginalClassroomList struct {
+	Items []OriginalClassroomItem `json:"items"`
+}
+
+// OriginalClassroomItem 空闲教室查询结果中的一间教室
+type OriginalClassroomItem struct {
+	CdId   string `json:"cd_id"`  // 场地 id
+	Cdmc   string `json:"cdmc"`   // 场地名称，如 7101
+	Xqmc   string `json:"xqmc"`   // 校区
+	Jxlmc  string `json:"jxlmc"`  // 教学楼
+	Zws    string `json:"zws"`    // 座位数
+	Cdlbmc string `json:"cdlbmc"` // 场地类别，如 多媒体教室
+}
+
+// errNoBorrowableSession 没有可以借用的教务系统会话
+var errNoBorrowableSession = errors.New("没有可用的教务系统会话")
+
+// SearchFreeClassrooms 查询当前学期某个时间段的空闲教室。结果对所有人都一样，按学期和查询条件缓存。
+// 优先借用缓存中任意一个本科生的会话查询，借用的会话都查询失败时才使用调用方自己的账号
+func (c *ccnuService) SearchFreeClassrooms(ctx context.Context, studentId, password string, q domain.FreeClassroomQuery) ([]domain.Classroom, error) {
+	t, ok := c.calendar.CurrentTerm(time.Now())
+	if !ok {
+		return nil, ccnuv1.ErrorUnknownTerm("校历中还没有任何学期")
+	}
+	if err := c.checkFreeClassroomQuery(t, q); err != nil {
+		return nil, err
+	}
+	key := classroomKey{year: t.Year, term: t.Term, query: q}
+	if rooms, ok := c.classrooms.get(key); ok {
+		return rooms, nil
+	}
+	var data OriginalClassroomList
+	fn := func(sess *session) error {
+		var er error
+		data, er = c.queryFreeClassrooms(ctx, sess, t, q)
+		return er
+	}
+	err := c.borrowXK(ctx, fn)
+	if err != nil && ctx.Err() == nil {
+		_, hasToken := sessionTokenFromContext(ctx)
+		switch {
+		case studentId != "" || hasToken:
+			err = c.doXK(ctx, studentId, password, fn)
+		case errors.Is(err, errNoBorrowableSession):
+			err = ccnuv1.ErrorNoSessionAvailable("暂时没有可用的教务系统会话，请携带账号查询")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	rooms := make([]domain.Classroom, 0, len(data.Items))
+	for _, item := range data.Items {
+		capacity, _ := strconv.Atoi(item.Zws)
+		rooms = append(rooms, domain.Classroom{
+			Id:       item.CdId,
+			Name:     item.Cdmc,
+			Campus:   item.Xqmc,
+			Building: item.Jxlmc,
+			Capacity: capacity,
+			Type:     item.Cdlbmc,
+		})
+	}
+	c.classrooms.put(key, rooms)
+	return rooms, nil
+}
+
+func (c *ccnuService) checkFreeClassroomQuery(t domain.Term, q domain.FreeClassroomQuery) error {
+	switch {
+	case q.Campus == "":
+		return ccnuv1.ErrorInvalidArgument("需要指定校区")
+	case q.Week < 1 || q.Week > t.Weeks:
+		return ccnuv1.ErrorInvalidArgument("周次有误: %d", q.Week)
+	case q.Weekday < 1 || q.Weekday > 7:
+		return ccnuv1.ErrorInvalidArgument("星期有误: %d", q.Weekday)
+	case q.StartPeriod < 1 || q.StartPeriod > q.EndPeriod || q.EndPeriod > len(c.calendar.Periods()):
+		return ccnuv1.ErrorInvalidArgument("节次有误: %d-%d", q.StartPeriod, q.EndPeriod)
+	}
+	return nil
+}
+
+// borrowXK 借用缓存中最近活跃的本科生会话请求教务系统，一个会话查询失败时换下一个，已失效的会话顺便移除。
+// 都失败时返回最后一个错误，没有可借用的会话时返回 errNoBorrowableSession。
+// 借用不算学生使用过，不会影响会话的过期时间和保活顺序
+func (c *ccnuService) borrowXK(ctx context.Context, fn func(sess *session) error) error {
+	err := errNoBorrowableSession
+	for _, sess := range c.sessions.active(time.Time{}, 3) {
+		if sess.system != c.undergraduate {
+			continue
+		}
+		er := fn(sess)
+		if er == nil || ctx.Err() != nil {
+			return contextError(er)
+		}
+		if errors.Is(er, errSessionExpired) {
+			c.sessions.remove(sess)
+			continue
+		}
+		err = er
+	}
+	return contextError(err)
+}
+
+func (c *ccnuService) queryFreeClassrooms(ctx context.Context, sess *session, t domain.Term, q domain.FreeClassroomQuery) (OriginalClassroomList, error) {
+	xnm, xqm := c.calendar.XKParams(t.Year, t.Term)
+	// 周次和节次都是位图，第 n 周、第 n 节对应第 n-1 位
+	var periods int64
+	for p := q.StartPeriod; p <= q.EndPeriod; p++ {
+		periods |= 1 << (p - 1)
+	}
+	formData := url.Values{}
+	formData.Set("fwzt", "cx")
+	formData.Set("xqh_id", q.Campus)
+	formData.Set("xnm", xnm)
+	formData.Set("xqm", xqm)
+	formData.Set("cdlb_id", "")
+	formData.Set("qszws", "")
+	formData.Set("jszws", "")
+	formData.Set("cdmc", "")
+	formData.Set("lh", q.Building)
+	formData.Set("jyfs", "0")
+	formData.Set("cdjylx", "")
+	formData.Set("zcd", strconv.FormatInt(1<<(q.Week-1), 10))
+	formData.Set("xqj", strconv.Itoa(q.Weekday))
+	formData.Set("jcd", strconv.FormatInt(periods, 10))
+	formData.Set("_search", "false")
+	formData.Set("nd", strconv.FormatInt(time.Now().UnixNano(), 10))
+	formData.Set("queryModel.showCount", "1000")
+	formData.Set("queryModel.currentPage", "1")
+	formData.Set("queryModel.sortName", "cdbh")
+	formData.Set("queryModel.sortOrder", "asc")
+	formData.Set("time", "1")
+
+	requestUrl := sess.system.cfg.FreeClassroom.URL(url.Values{"doType": {"query"}})
+	req, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "POST", requestUrl, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return OriginalClassroomList{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+	req.Header.Set("Origin", sess.system.cfg.FreeClassroom.Origin())
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+
+	resp, err := sess.client.Do(req)
+	if err != nil {
+		return OriginalClassroomList{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return OriginalClassroomList{}, err
+	}
+	if err = checkXKResponse(resp, body); err != nil {
+		return OriginalClassroomList{}, err
+	}
+	var data OriginalClassroomList
+	err = decodeXKJSON(body, &data)
+	return data, err
+}
+
+type classroomEntry struct {
+	rooms    []domain.Classroom
+	expireAt time.Time
+}
+
+// classroomKey 同样的查询条件在不同学期的结果不同
+type classroomKey struct {
+	year  string
+	term  string
+	query domain.FreeClassroomQuery
+}
+
+// classroomCache 按学期和查询条件缓存空闲教室
+type classroomCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[classroomKey]classroomEntry
+}
+
+func newClassroomCache(ttl time.Duration) *classroomCache {
+	return &classroomCache{
+		ttl:     ttl,
+		entries: make(map[classroomKey]classroomEntry),
+	}
+}
+
+func (s *classroomCache) get(q classroomKey) ([]domain.Classroom, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[q]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expireAt) {
+		delete(s.entries, q)
+		return nil, false
+	}
+	return e.rooms, true
+}
+
+func (s *classroomCache) put(q classroomKey, rooms []domain.Classroom) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, v := range s.entries {
+		if now.After(v.expireAt) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[q] = classroomEntry{rooms: rooms, expireAt: now.Add(s.ttl)}
+}
@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestSearchFreeClassrooms(t *testing.T) {
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	fake.SetFreeClassrooms("1", 3, 2, 1, 2, []service.OriginalClassroomItem{
+		{CdId: "7101", Cdmc: "7101", Xqmc: "本部", Jxlmc: "7号楼", Zws: "120", Cdlbmc: "多媒体教室"},
+	})
+	ctx := context.Background()
+	q := domain.FreeClassroomQuery{Campus: "1", Week: 3, Weekday: 2, StartPeriod: 1, EndPeriod: 2}
+
+	if _, err := svc.SearchFreeClassrooms(ctx, "", "", q); !ccnuv1.IsNoSessionAvailable(err) {
+		t.Fatalf("没有可借用的会话时应该要求携带账号: %v", err)
+	}
+	if ok, err := svc.Login(ctx, undergraduateId, password); !ok || err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.Classroom{{Id: "7101", Name: "7101", Campus: "本部", Building: "7号楼", Capacity: 120, Type: "多媒体教室"}}
+	for i := 0; i < 2; i++ {
+		rooms, err := svc.SearchFreeClassrooms(ctx, "", "", q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rooms) != 1 || rooms[0] != want[0] {
+			t.Fatalf("空闲教室有误: %+v", rooms)
+		}
+	}
+	if got := fake.Hits(fakeccnu.RouteClassroom); got != 1 {
+		t.Fatalf("相同的查询应该命中缓存，实际请求了 %d 次", got)
+	}
+
+	// 借用的会话失效后，用调用方自己的账号查询
+	fake.ExpireSessions()
+	other := q
+	other.Weekday = 3
+	if _, err := svc.SearchFreeClassrooms(ctx, undergraduateId, password, other); err != nil {
+		t.Fatalf("借用失败后应该使用调用方的账号: %v", err)
+	}
+
+	for _, bad := range []domain.FreeClassroomQuery{
+		{Week: 3, Weekday: 2, StartPeriod: 1, EndPeriod: 2},
+		{Campus: "1", Week: 19, Weekday: 2, StartPeriod: 1, EndPeriod: 2},
+		{Campus: "1", Week: 3, Weekday: 8, StartPeriod: 1, EndPeriod: 2},
+		{Campus: "1", Week: 3, Weekday: 2, StartPeriod: 3, EndPeriod: 2},
+		{Campus: "1", Week: 3, Weekday: 2, StartPeriod: 1, EndPeriod: 13},
+	} {
+		if _, err := svc.SearchFreeClassrooms(ctx, "", "", bad); !ccnuv1.IsInvalidArgument(err) {
+			t.Errorf("%+v 应该是无效的查询: %v", bad, err)
+		}
+	}
+}
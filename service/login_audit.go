@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/repository"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/go-kratos/kratos/v2/errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginAuditConfig 登录审计配置
+type LoginAuditConfig struct {
+	// Retention 审计记录保留多久
+	Retention time.Duration
+	// PruneInterval 每隔多久清理一次过期的记录，为 0 时不清理
+	PruneInterval time.Duration
+	// QueueSize 等待写入的记录最多有多少条，写不过来时丢弃新的记录
+	QueueSize int
+}
+
+// LoginAuditor 记录每一次登录尝试，包括命中缓存的登录，用来排查学生反馈的登录问题。
+// 记录由 Start 启动的一个后台协程依次写入
+type LoginAuditor struct {
+	repo  repository.LoginAttemptRepository
+	cfg   LoginAuditConfig
+	l     logger.Logger
+	queue chan domain.LoginAttempt
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewLoginAuditor(repo repository.LoginAttemptRepository, cfg LoginAuditConfig, l logger.Logger) *LoginAuditor {
+	return &LoginAuditor{
+		repo:  repo,
+		cfg:   cfg,
+		l:     l,
+		queue: make(chan domain.LoginAttempt, max(cfg.QueueSize, 1)),
+	}
+}
+
+// Record 把一条记录放进写入队列，不会阻塞登录；队列满了时丢弃并打日志
+func (a *LoginAuditor) Record(attempt domain.LoginAttempt) {
+	select {
+	case a.queue <- attempt:
+	default:
+		a.l.Warn("登录审计队列已满，丢弃记录", logger.String("studentId", attempt.StudentId),
+			logger.String("outcome", attempt.Outcome))
+	}
+}
+
+func (a *LoginAuditor) insert(attempt domain.LoginAttempt) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := a.repo.Insert(ctx, repository.LoginAttempt{
+		StudentId: attempt.StudentId,
+		Caller:    attempt.Caller,
+		Outcome:   attempt.Outcome,
+		LatencyMs: attempt.Latency.Milliseconds(),
+		Ctime:     attempt.Time.UnixMilli(),
+	})
+	if err != nil {
+		a.l.Error("写入登录审计记录失败", logger.String("studentId", attempt.StudentId), logger.Error(err))
+	}
+}
+
+func (a *LoginAuditor) Find(ctx context.Context, studentId string, start, end time.Time, offset, limit int) ([]domain.LoginAttempt, error) {
+	attempts, err := a.repo.Find(ctx, studentId, start.UnixMilli(), end.UnixMilli(), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return slice.Map(attempts, func(idx int, src repository.LoginAttempt) domain.LoginAttempt {
+		return domain.LoginAttempt{
+			StudentId: src.StudentId,
+			Caller:    src.Caller,
+			Outcome:   src.Outcome,
+			Latency:   time.Duration(src.LatencyMs) * time.Millisecond,
+			Time:      time.UnixMilli(src.Ctime),
+		}
+	}), nil
+}
+
+// Start 启动写入记录的后台协程，并定期清理超过保留期限的记录
+func (a *LoginAuditor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.done = make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.write(ctx)
+	}()
+	if a.cfg.PruneInterval > 0 && a.cfg.Retention > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.prune(ctx)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(a.done)
+	}()
+}
+
+// write 依次写入队列里的记录，停止时把已经在队列里的记录写完
+func (a *LoginAuditor) write(ctx context.Context) {
+	for {
+		select {
+		case attempt := <-a.queue:
+			a.insert(attempt)
+		case <-ctx.Done():
+			for {
+				select {
+				case attempt := <-a.queue:
+					a.insert(attempt)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (a *LoginAuditor) prune(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.PruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cnt, err := a.repo.DeleteBefore(ctx, time.Now().Add(-a.cfg.Retention).UnixMilli())
+			if err != nil {
+				a.l.Error("清理登录审计记录失败", logger.Error(err))
+				continue
+			}
+			a.l.Debug("清理登录审计记录", logger.Int64("count", cnt))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *LoginAuditor) Stop() {
+	if a.cancel == nil {
+		return
+	}
+	a.cancel()
+	<-a.done
+}
+
+type callerKey struct{}
+
+// WithCaller 把调用方服务的名字放进 context，用于登录审计
+func WithCaller(ctx context.Context, caller string) context.Context {
+	if caller == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func callerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// auditLogin 记录一次登录尝试，start 是开始登录的时间
+func (c *ccnuService) auditLogin(ctx context.Context, studentId string, start time.Time, err error) {
+	c.audit.Record(domain.LoginAttempt{
+		StudentId: studentId,
+		Caller:    callerFromContext(ctx),
+		Outcome:   loginOutcome(err),
+		Latency:   time.Since(start),
+		Time:      start,
+	})
+}
+
+// auditCachedLogin 记录一次命中缓存会话的登录，这种登录不会请求 CAS
+func (c *ccnuService) auditCachedLogin(ctx context.Context, studentId string, start time.Time) {
+	c.audit.Record(domain.LoginAttempt{
+		StudentId: studentId,
+		Caller:    callerFromContext(ctx),
+		Outcome:   "cached",
+		Latency:   time.Since(start),
+		Time:      start,
+	})
+}
+
+// loginOutcome 登录结果的分类，成功为 success，失败为小写的错误原因
+func loginOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	if reason := errors.Reason(err); reason != "" {
+		return strings.ToLower(reason)
+	}
+	return "error"
+}
+
+func (c *ccnuService) ListLoginAttempts(ctx context.Context, studentId string, start, end time.Time, offset, limit int) ([]domain.LoginAttempt, error) {
+	return c.audit.Find(ctx, studentId, start, end, offset, limit)
+}
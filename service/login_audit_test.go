@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/repository"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLoginAuditor(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryLoginAttemptRepository()
+	a := service.NewLoginAuditor(repo, service.LoginAuditConfig{QueueSize: 2}, logger.NewNopLogger())
+	now := time.Now()
+
+	// 还没有启动时记录留在队列里，队列满了丢弃新的记录，不会阻塞
+	for i := 0; i < 3; i++ {
+		a.Record(domain.LoginAttempt{StudentId: undergraduateId, Outcome: "success", Latency: time.Duration(i) * time.Millisecond, Time: now.Add(time.Duration(i) * time.Second)})
+	}
+	a.Start()
+	// 停止时把队列里的记录写完
+	a.Stop()
+
+	attempts, err := a.Find(ctx, undergraduateId, now.Add(-time.Minute), now.Add(time.Minute), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("队列满了之后的记录应该被丢弃: %+v", attempts)
+	}
+	// 新的记录排在前面
+	if !attempts[0].Time.After(attempts[1].Time) || attempts[0].Latency != time.Millisecond {
+		t.Fatalf("记录有误: %+v", attempts)
+	}
+	if attempts, _ = a.Find(ctx, undergraduateId, now.Add(-time.Minute), now.Add(time.Minute), 1, 10); len(attempts) != 1 {
+		t.Fatalf("分页有误: %+v", attempts)
+	}
+	if attempts, _ = a.Find(ctx, "2023214002", now.Add(-time.Minute), now.Add(time.Minute), 0, 10); len(attempts) != 0 {
+		t.Fatalf("不应该查到其它学号的记录: %+v", attempts)
+	}
+}
+
+func TestLoginAuditorPrune(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryLoginAttemptRepository()
+	a := service.NewLoginAuditor(repo, service.LoginAuditConfig{Retention: time.Hour, PruneInterval: 10 * time.Millisecond, QueueSize: 10},
+		logger.NewNopLogger())
+	now := time.Now()
+	a.Start()
+	defer a.Stop()
+	a.Record(domain.LoginAttempt{StudentId: undergraduateId, Outcome: "success", Time: now.Add(-2 * time.Hour)})
+	a.Record(domain.LoginAttempt{StudentId: undergraduateId, Outcome: "success", Time: now})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		attempts, err := a.Find(ctx, undergraduateId, now.Add(-3*time.Hour), now.Add(time.Minute), 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attempts) == 1 && attempts[0].Time.UnixMilli() == now.UnixMilli() {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("超过保留期限的记录应该被清理: %+v", attempts)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestListLoginAttempts(t *testing.T) {
+	_, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	ctx := service.WithCaller(context.Background(), "be-user")
+	start := time.Now().Add(-time.Second)
+
+	if _, err := svc.Login(ctx, undergraduateId, password); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Login(ctx, undergraduateId, password); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = svc.Login(ctx, undergraduateId, "wrong")
+
+	// 记录是异步写入的
+	var attempts []domain.LoginAttempt
+	deadline := time.Now().Add(time.Second)
+	for len(attempts) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("应该有 3 条记录: %+v", attempts)
+		}
+		time.Sleep(5 * time.Millisecond)
+		var err error
+		if attempts, err = svc.ListLoginAttempts(ctx, undergraduateId, start, time.Now().Add(time.Second), 0, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var outcomes []string
+	for _, a := range attempts {
+		if a.Caller != "be-user" || a.StudentId != undergraduateId {
+			t.Fatalf("记录有误: %+v", a)
+		}
+		outcomes = append(outcomes, a.Outcome)
+	}
+	sort.Strings(outcomes)
+	if want := []string{"cached", "invalid_sid_or_pwd", "success"}; !reflect.DeepEqual(outcomes, want) {
+		t.Fatalf("登录结果有误: %v", outcomes)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/pkg/limiter"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LoginLimitConfig CAS 登录限流配置
+type LoginLimitConfig struct {
+	// GlobalRate、GlobalBurst 所有学号共享的 account.ccnu.edu.cn 登录速率（次/秒）
+	GlobalRate  float64
+	GlobalBurst int
+	// StudentRate、StudentBurst 单个学号的登录速率（次/秒）
+	StudentRate  float64
+	StudentBurst int
+	// MaxBadPasswords 连续密码错误多少次后暂时不再替该学号登录，Lockout 是暂停的时长
+	MaxBadPasswords int
+	Lockout         time.Duration
+}
+
+// LoginLimiter 挡在 CAS 登录前面的限流器，防止网关重试风暴把学生的账号锁住
+type LoginLimiter struct {
+	cfg       LoginLimitConfig
+	global    *limiter.TokenBucket
+	mu        sync.Mutex
+	students  map[string]*studentLoginState
+	lastSweep time.Time
+}
+
+type studentLoginState struct {
+	bucket       *limiter.TokenBucket
+	badPasswords int
+	lockedUntil  time.Time
+}
+
+func NewLoginLimiter(cfg LoginLimitConfig) (*LoginLimiter, error) {
+	if cfg.GlobalRate <= 0 || cfg.GlobalBurst < 1 {
+		return nil, fmt.Errorf("全局登录速率必须大于 0，突发次数至少为 1")
+	}
+	if cfg.StudentRate <= 0 || cfg.StudentBurst < 1 {
+		return nil, fmt.Errorf("单个学号的登录速率必须大于 0，突发次数至少为 1")
+	}
+	return &LoginLimiter{
+		cfg:       cfg,
+		global:    limiter.NewTokenBucket(cfg.GlobalRate, cfg.GlobalBurst),
+		students:  make(map[string]*studentLoginState),
+		lastSweep: time.Now(),
+	}, nil
+}
+
+// Allow 检查现在能否替该学号发起一次 CAS 登录，不能时返回带 retry_after（秒）的 ResourceExhausted 错误
+func (l *LoginLimiter) Allow(studentId string) error {
+	now := time.Now()
+	l.mu.Lock()
+	l.sweep(now)
+	state, ok := l.students[studentId]
+	if !ok {
+		state = &studentLoginState{
+			bucket: limiter.NewTokenBucket(l.cfg.StudentRate, l.cfg.StudentBurst),
+		}
+		l.students[studentId] = state
+	}
+	lockedUntil := state.lockedUntil
+	l.mu.Unlock()
+
+	if now.Before(lockedUntil) {
+		return tooManyRequests("密码错误次数过多，请稍后再试", lockedUntil.Sub(now))
+	}
+	if ok, wait := state.bucket.Allow(); !ok {
+		return tooManyRequests("登录过于频繁，请稍后再试", wait)
+	}
+	if ok, wait := l.global.Allow(); !ok {
+		// 这次登录没有发出去，不能算在该学号头上
+		state.bucket.Refund()
+		return tooManyRequests("登录人数过多，请稍后再试", wait)
+	}
+	return nil
+}
+
+// Record 记录一次 CAS 登录的结果，连续密码错误达到上限后锁定该学号
+func (l *LoginLimiter) Record(studentId string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, ok := l.students[studentId]
+	if !ok {
+		return
+	}
+	switch {
+	case err == nil:
+		state.badPasswords = 0
+	case ccnuv1.IsInvalidSidOrPwd(err):
+		state.badPasswords++
+		if state.badPasswords >= l.cfg.MaxBadPasswords {
+			state.badPasswords = 0
+			state.lockedUntil = time.Now().Add(l.cfg.Lockout)
+		}
+	}
+}
+
+// sweep 清理已经恢复初始状态的学号，调用方需要持有锁
+func (l *LoginLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	for k, v := range l.students {
+		if v.badPasswords == 0 && now.After(v.lockedUntil) && v.bucket.Full() {
+			delete(l.students, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+func tooManyRequests(msg string, retryAfter time.Duration) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	return ccnuv1.ErrorTooManyRequests(msg).WithMetadata(map[string]string{
+		"retry_after": strconv.Itoa(seconds),
+	})
+}
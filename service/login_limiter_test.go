@@ -0,0 +1,124 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"github.com/go-kratos/kratos/v2/errors"
+	"testing"
+	"time"
+)
+
+func newLoginLimiter(t *testing.T, cfg service.LoginLimitConfig) *service.LoginLimiter {
+	t.Helper()
+	l, err := service.NewLoginLimiter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLoginLimiter(t *testing.T) {
+	badPwd := ccnuv1.ErrorInvalidSidOrPwd("学号或密码错误")
+
+	t.Run("单个学号限速", func(t *testing.T) {
+		l := newLoginLimiter(t, service.LoginLimitConfig{GlobalRate: 100, GlobalBurst: 100, StudentRate: 0.01, StudentBurst: 2, MaxBadPasswords: 5})
+		for i := 0; i < 2; i++ {
+			if err := l.Allow(undergraduateId); err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := l.Allow(undergraduateId)
+		if !ccnuv1.IsTooManyRequests(err) {
+			t.Fatalf("超过速率应该被拒绝: %v", err)
+		}
+		if errors.FromError(err).Metadata["retry_after"] == "" {
+			t.Fatal("应该带上 retry_after")
+		}
+		if err = l.Allow("2023214002"); err != nil {
+			t.Fatalf("其它学号不受影响: %v", err)
+		}
+	})
+
+	t.Run("全局限速", func(t *testing.T) {
+		l := newLoginLimiter(t, service.LoginLimitConfig{GlobalRate: 0.01, GlobalBurst: 1, StudentRate: 100, StudentBurst: 100, MaxBadPasswords: 5})
+		if err := l.Allow(undergraduateId); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Allow("2023214002"); !ccnuv1.IsTooManyRequests(err) {
+			t.Fatalf("超过全局速率应该被拒绝: %v", err)
+		}
+	})
+
+	t.Run("被全局限速挡住不消耗学号的令牌", func(t *testing.T) {
+		l := newLoginLimiter(t, service.LoginLimitConfig{GlobalRate: 0.01, GlobalBurst: 1, StudentRate: 0.01, StudentBurst: 1, MaxBadPasswords: 5})
+		if err := l.Allow("2023214002"); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Allow(undergraduateId); !ccnuv1.IsTooManyRequests(err) {
+			t.Fatalf("超过全局速率应该被拒绝: %v", err)
+		}
+		if err := errors.FromError(l.Allow(undergraduateId)); err.Message != "登录人数过多，请稍后再试" {
+			t.Fatalf("学号的令牌应该还在，只是被全局限速挡住: %v", err)
+		}
+	})
+
+	t.Run("无效的配置", func(t *testing.T) {
+		for _, cfg := range []service.LoginLimitConfig{
+			{GlobalRate: 0, GlobalBurst: 1, StudentRate: 1, StudentBurst: 1},
+			{GlobalRate: 1, GlobalBurst: 1, StudentRate: 1, StudentBurst: 0},
+		} {
+			if _, err := service.NewLoginLimiter(cfg); err == nil {
+				t.Errorf("应该拒绝 %+v", cfg)
+			}
+		}
+	})
+
+	t.Run("连续密码错误", func(t *testing.T) {
+		l := newLoginLimiter(t, service.LoginLimitConfig{GlobalRate: 100, GlobalBurst: 100, StudentRate: 100, StudentBurst: 100,
+			MaxBadPasswords: 3, Lockout: time.Minute})
+		for i := 0; i < 2; i++ {
+			_ = l.Allow(undergraduateId)
+			l.Record(undergraduateId, badPwd)
+		}
+		// 登录成功会清零
+		_ = l.Allow(undergraduateId)
+		l.Record(undergraduateId, nil)
+		for i := 0; i < 2; i++ {
+			_ = l.Allow(undergraduateId)
+			l.Record(undergraduateId, badPwd)
+		}
+		if err := l.Allow(undergraduateId); err != nil {
+			t.Fatalf("还没有连续错 3 次: %v", err)
+		}
+		l.Record(undergraduateId, badPwd)
+		err := l.Allow(undergraduateId)
+		if !ccnuv1.IsTooManyRequests(err) {
+			t.Fatalf("连续错 3 次应该暂停登录: %v", err)
+		}
+		if got := errors.FromError(err).Metadata["retry_after"]; got != "60" {
+			t.Fatalf("retry_after 应该是锁定的时长，got %s", got)
+		}
+	})
+}
+
+func TestLoginLimiterStopsBadPasswords(t *testing.T) {
+	limits := testLimits
+	limits.MaxBadPasswords = 2
+	fake, svc := newLimitedTestService(t, limits, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	ctx := context.Background()
+
+	for i := 0; i < limits.MaxBadPasswords; i++ {
+		if _, err := svc.Login(ctx, undergraduateId, "wrong"); !ccnuv1.IsInvalidSidOrPwd(err) {
+			t.Fatalf("第 %d 次应该是密码错误: %v", i+1, err)
+		}
+	}
+	hits := fake.Hits(fakeccnu.RouteCASLogin)
+	if _, err := svc.Login(ctx, undergraduateId, password); !ccnuv1.IsTooManyRequests(err) {
+		t.Fatalf("连续输错密码之后应该暂停登录: %v", err)
+	}
+	if got := fake.Hits(fakeccnu.RouteCASLogin); got != hits {
+		t.Fatalf("暂停登录期间不应该再请求 CAS，多请求了 %d 次", got-hits)
+	}
+}
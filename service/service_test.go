@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"github.com/asynccnu/be-ccnu/pkg/cryptox"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/repository"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+	"time"
+)
+
+const (
+	undergraduateId = "2023214001"
+	password        = "pwd-123456"
+)
+
+// testLimits 足够宽松，只有专门测试限流的用例才会触发
+var testLimits = service.LoginLimitConfig{
+	GlobalRate:      1000,
+	GlobalBurst:     1000,
+	StudentRate:     1000,
+	StudentBurst:    1000,
+	MaxBadPasswords: 5,
+	Lockout:         time.Minute,
+}
+
+// testCalendar 2023-2024 学年第一学期，国庆放假 7 天，10 月 7 日（周六）补 10 月 6 日（周五）的课
+var testCalendar = service.CalendarConfig{
+	Periods: []service.PeriodTime{
+		{Start: "08:00", End: "08:45"}, {Start: "08:55", End: "09:40"},
+		{Start: "10:10", End: "10:55"}, {Start: "11:05", End: "11:50"},
+		{Start: "14:00", End: "14:45"}, {Start: "14:55", End: "15:40"},
+		{Start: "16:10", End: "16:55"}, {Start: "17:05", End: "17:50"},
+		{Start: "18:30", End: "19:15"}, {Start: "19:25", End: "20:10"},
+		{Start: "20:20", End: "21:05"}, {Start: "21:15", End: "22:00"},
+	},
+	Terms: []service.TermConfig{{
+		Year:     "2023",
+		Term:     "1",
+		Start:    "2023-09-04",
+		Weeks:    18,
+		Holidays: []service.HolidayConfig{{Name: "国庆节", From: "2023-09-29", To: "2023-10-06"}},
+		Workdays: []service.WorkdayConfig{{Date: "2023-10-07", As: "2023-10-06"}},
+	}},
+}
+
+func newTestService(t *testing.T, accounts ...fakeccnu.Account) (*fakeccnu.Server, service.CCNUService) {
+	return newLimitedTestService(t, testLimits, accounts...)
+}
+
+func newLimitedTestService(t *testing.T, limits service.LoginLimitConfig, accounts ...fakeccnu.Account) (*fakeccnu.Server, service.CCNUService) {
+	t.Helper()
+	return newTestServiceWith(t, limits, service.KeepAliveConfig{}, accounts...)
+}
+
+// newTestServiceWith 启动假上游，创建一个指向它的 ccnuService，测试结束时关闭假上游
+func newTestServiceWith(t *testing.T, limits service.LoginLimitConfig, keepAlive service.KeepAliveConfig,
+	accounts ...fakeccnu.Account) (*fakeccnu.Server, service.CCNUService) {
+	t.Helper()
+	fake := fakeccnu.NewServer(accounts...)
+	t.Cleanup(fake.Close)
+	l := logger.NewNopLogger()
+	egress, err := httpx.NewEgressPool(httpx.TransportConfig{}, httpx.EgressPoolConfig{}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar, err := service.NewAcademicCalendar(testCalendar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := cryptox.NewKeyring("k1", map[string][]byte{"k1": []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault := service.NewCredentialVault(repository.NewMemoryCredentialRepository(), keyring, l)
+	audit := service.NewLoginAuditor(repository.NewMemoryLoginAttemptRepository(), service.LoginAuditConfig{QueueSize: 100}, l)
+	audit.Start()
+	t.Cleanup(audit.Stop)
+	limiter, err := service.NewLoginLimiter(limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resilience := httpx.NewResilience(httpx.ResilienceConfig{})
+	prober := service.NewUpstreamProber(fake.Upstream(), egress, resilience, service.ProbeConfig{}, l)
+	svc := service.NewCCNUService(fake.Upstream(), egress, resilience, vault,
+		limiter, keepAlive, audit, prober, calendar, l)
+	return fake, svc
+}
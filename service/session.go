@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errSessionExpired 上游会话已失效（被重定向回 CAS，或返回了登录页），需要重新登录
+var errSessionExpired = errors.New("上游会话已失效")
+
+// session 一个学生已登录教务系统的会话
+type session struct {
+	studentId string
+	system    *zfSystem
+	// 只保存密码摘要，用于校验调用方提供的密码和登录时一致
+	pwdDigest [sha256.Size]byte
+	client    *http.Client
+	expireAt  time.Time
+	// lastUsed 学生最后一次使用这个会话的时间，后台保活不会更新它
+	lastUsed time.Time
+}
+
+// sessionCache 按学号缓存已登录的会话，每次命中都会顺延过期时间
+type sessionCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	sessions  map[string]*session
+	lastSweep time.Time
+}
+
+func newSessionCache(ttl time.Duration) *sessionCache {
+	return &sessionCache{
+		ttl:       ttl,
+		sessions:  make(map[string]*session),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *sessionCache) get(studentId, password string) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[studentId]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if now.After(sess.expireAt) {
+		delete(s.sessions, studentId)
+		return nil, false
+	}
+	digest := sha256.Sum256([]byte(password))
+	if subtle.ConstantTimeCompare(digest[:], sess.pwdDigest[:]) != 1 {
+		return nil, false
+	}
+	sess.expireAt = now.Add(s.ttl)
+	sess.lastUsed = now
+	return sess, true
+}
+
+func (s *sessionCache) put(studentId, password string, system *zfSystem, client *http.Client) *session {
+	sess := &session{
+		studentId: studentId,
+		system:    system,
+		pwdDigest: sha256.Sum256([]byte(password)),
+		client:    client,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	sess.expireAt = now.Add(s.ttl)
+	sess.lastUsed = now
+	s.sessions[studentId] = sess
+	// 顺便清理已过期的会话，避免 map 无限增长
+	if now.Sub(s.lastSweep) > s.ttl {
+		for k, v := range s.sessions {
+			if now.After(v.expireAt) {
+				delete(s.sessions, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	return sess
+}
+
+// touch 检查会话是否还在缓存中且没有过期，是的话顺延过期时间
+func (s *sessionCache) touch(sess *session) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.sessions[sess.studentId] != sess || now.After(sess.expireAt) {
+		return false
+	}
+	sess.expireAt = now.Add(s.ttl)
+	sess.lastUsed = now
+	return true
+}
+
+// active 返回 since 之后用过的会话，最近用过的排在前面，最多 limit 个
+func (s *sessionCache) active(since time.Time, limit int) []*session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var res []*session
+	for _, sess := range s.sessions {
+		if sess.lastUsed.After(since) && now.Before(sess.expireAt) {
+			res = append(res, sess)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].lastUsed.After(res[j].lastUsed)
+	})
+	if len(res) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+// extend 上游会话刚被刷新过，顺延过期时间但不算作学生使用过。会话已经不在缓存中时返回 false
+func (s *sessionCache) extend(sess *session) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.sessions[sess.studentId] != sess || now.After(sess.expireAt) {
+		return false
+	}
+	sess.expireAt = now.Add(s.ttl)
+	return true
+}
+
+// remove 移除会话，如果缓存里已经换成了新登录的会话则不动
+func (s *sessionCache) remove(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[sess.studentId] == sess {
+		delete(s.sessions, sess.studentId)
+	}
+}
+
+// xkSession 获取已登录教务系统的会话，优先复用缓存。本科生登录 jwglxt，研究生登录 yjsxt。
+// 调用方携带了会话令牌时，直接使用令牌绑定的会话；只传了学号时，使用保存的密码登录
+func (c *ccnuService) xkSession(ctx context.Context, studentId, password string) (*session, error) {
+	var (
+		sess *session
+		err  error
+	)
+	if token, ok := sessionTokenFromContext(ctx); ok {
+		sess, err = c.tokenSession(ctx, token)
+	} else {
+		sess, _, err = c.passwordSession(ctx, studentId, password)
+	}
+	return sess, contextError(err)
+}
+
+// passwordSession 使用学号和密码获取会话，没有传密码时使用保存的密码。命中缓存时第二个返回值为 true
+func (c *ccnuService) passwordSession(ctx context.Context, studentId, password string) (*session, bool, error) {
+	password, fromVault, err := c.resolvePassword(ctx, studentId, password)
+	if err != nil {
+		return nil, false, err
+	}
+	if sess, ok := c.sessions.get(studentId, password); ok {
+		return sess, true, nil
+	}
+	system, err := c.systemOf(studentId)
+	if err != nil {
+		return nil, false, err
+	}
+	client, err := c.xkLoginClient(ctx, system, studentId, password)
+	if err != nil {
+		if fromVault {
+			c.forgetStaleCredential(ctx, studentId, err)
+		}
+		return nil, false, err
+	}
+	return c.sessions.put(studentId, password, system, client), false, nil
+}
+
+// doXK 使用缓存的会话请求教务系统，如果发现会话已失效，重新登录后再重试一次
+func (c *ccnuService) doXK(ctx context.Context, studentId, password string, fn func(sess *session) error) error {
+	sess, err := c.xkSession(ctx, studentId, password)
+	if err != nil {
+		return err
+	}
+	err = fn(sess)
+	if !errors.Is(err, errSessionExpired) {
+		return contextError(err)
+	}
+	c.sessions.remove(sess)
+	sess, err = c.xkSession(ctx, studentId, password)
+	if err != nil {
+		return err
+	}
+	err = fn(sess)
+	if errors.Is(err, errSessionExpired) {
+		return ccnuv1.ErrorUnexpectedResponse("重新登录后教务系统仍然要求登录")
+	}
+	return contextError(err)
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeepAliveConfig 后台会话保活配置
+type KeepAliveConfig struct {
+	// Interval 每隔多久刷新一轮，要小于教务系统的会话超时时间
+	Interval time.Duration
+	// ActiveWithin 只刷新这段时间内用过的会话，学生不再使用后会话会自然过期
+	ActiveWithin time.Duration
+	// MaxSessions 每轮最多刷新多少个会话，优先刷新最近用过的
+	MaxSessions int
+	// Concurrency 同时刷新的会话数
+	Concurrency int
+	// Jitter 每个会话刷新前随机等待 [0, Jitter)，把请求打散
+	Jitter time.Duration
+}
+
+// KeepAlive 刷新一轮最近活跃学生的会话，返回刷新成功的会话数。
+// 上游会话已经失效的会被移出缓存，不再刷新
+func (c *ccnuService) KeepAlive(ctx context.Context) int {
+	cfg := c.keepAlive
+	sessions := c.sessions.active(time.Now().Add(-cfg.ActiveWithin), cfg.MaxSessions)
+	var (
+		mu  sync.Mutex
+		cnt int
+		wg  sync.WaitGroup
+	)
+	sem := make(chan struct{}, max(cfg.Concurrency, 1))
+	for _, sess := range sessions {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return cnt
+		}
+		wg.Add(1)
+		go func(sess *session) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if cfg.Jitter > 0 {
+				t := time.NewTimer(time.Duration(rand.Int63n(int64(cfg.Jitter))))
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return
+				}
+			}
+			err := c.pingSession(ctx, sess)
+			switch {
+			case err == nil:
+				if c.sessions.extend(sess) {
+					mu.Lock()
+					cnt++
+					mu.Unlock()
+				}
+			case errors.Is(err, errSessionExpired):
+				c.sessions.remove(sess)
+			case ctx.Err() != nil:
+				// 服务正在退出
+			default:
+				c.l.Warn("会话保活失败", logger.String("studentId", sess.studentId), logger.Error(err))
+			}
+		}(sess)
+	}
+	wg.Wait()
+	return cnt
+}
+
+// pingSession 访问一个轻量的页面，让教务系统顺延会话
+func (c *ccnuService) pingSession(ctx context.Context, sess *session) error {
+	req, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "GET", sess.system.cfg.KeepAlive.URL(nil), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := sess.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return checkXKRedirect(resp)
+}
+
+// keepAliver 保活只在进程内部使用，不属于对外的 CCNUService
+type keepAliver interface {
+	KeepAlive(ctx context.Context) int
+}
+
+// SessionKeeper 定时调用 KeepAlive，让最近活跃学生的会话不会因为闲置而失效
+type SessionKeeper struct {
+	svc      keepAliver
+	interval time.Duration
+	l        logger.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSessionKeeper svc 必须是 NewCCNUService 创建的实例
+func NewSessionKeeper(svc CCNUService, cfg KeepAliveConfig, l logger.Logger) *SessionKeeper {
+	ka, ok := svc.(keepAliver)
+	if !ok {
+		panic(fmt.Sprintf("%T 不支持会话保活", svc))
+	}
+	return &SessionKeeper{
+		svc:      ka,
+		interval: cfg.Interval,
+		l:        l,
+	}
+}
+
+// Start 在后台开始保活，Interval 不大于 0 时不启动
+func (k *SessionKeeper) Start() {
+	if k.interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel = cancel
+	k.done = make(chan struct{})
+	go func() {
+		defer close(k.done)
+		ticker := time.NewTicker(k.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				start := time.Now()
+				cnt := k.svc.KeepAlive(ctx)
+				k.l.Debug("会话保活", logger.Int("count", cnt),
+					logger.String("cost", time.Since(start).String()))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止保活，等待正在进行的一轮结束
+func (k *SessionKeeper) Stop() {
+	if k.cancel == nil {
+		return
+	}
+	k.cancel()
+	<-k.done
+}
@@ -0,0 +1,103 @@
+package service_test
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+	"time"
+)
+
+// keepAliver 保活不在 CCNUService 接口里
+type keepAliver interface {
+	KeepAlive(ctx context.Context) int
+}
+
+func TestKeepAlive(t *testing.T) {
+	const otherId = "2023214002"
+	fake, svc := newTestServiceWith(t, testLimits,
+		service.KeepAliveConfig{ActiveWithin: time.Minute, MaxSessions: 10, Concurrency: 2},
+		fakeccnu.Account{StudentId: undergraduateId, Password: password},
+		fakeccnu.Account{StudentId: otherId, Password: password},
+	)
+	ctx := context.Background()
+	ka := svc.(keepAliver)
+
+	for _, id := range []string{undergraduateId, otherId} {
+		if _, err := svc.GetTimetable(ctx, id, password, "2023", "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cnt := ka.KeepAlive(ctx); cnt != 2 || fake.Hits(fakeccnu.RouteKeepAlive) != 2 {
+		t.Fatalf("应该刷新两个会话: %d, 访问了 %d 次", cnt, fake.Hits(fakeccnu.RouteKeepAlive))
+	}
+
+	// 上游会话失效后移出缓存，下一轮不再刷新
+	fake.ExpireSessions()
+	if cnt := ka.KeepAlive(ctx); cnt != 0 {
+		t.Fatalf("失效的会话不算刷新成功: %d", cnt)
+	}
+	hits := fake.Hits(fakeccnu.RouteKeepAlive)
+	if cnt := ka.KeepAlive(ctx); cnt != 0 || fake.Hits(fakeccnu.RouteKeepAlive) != hits {
+		t.Fatalf("失效的会话不应该再刷新: %d", cnt)
+	}
+
+	// 重新登录后的会话继续保活
+	logins := fake.Hits(fakeccnu.RouteCASLogin)
+	if _, err := svc.GetTimetable(ctx, undergraduateId, password, "2023", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Hits(fakeccnu.RouteCASLogin) == logins {
+		t.Fatal("会话被移出缓存后应该重新登录")
+	}
+	if cnt := ka.KeepAlive(ctx); cnt != 1 {
+		t.Fatalf("应该刷新重新登录的会话: %d", cnt)
+	}
+}
+
+func TestKeepAliveMaxSessions(t *testing.T) {
+	fake, svc := newTestServiceWith(t, testLimits,
+		service.KeepAliveConfig{ActiveWithin: time.Minute, MaxSessions: 1},
+		fakeccnu.Account{StudentId: undergraduateId, Password: password},
+		fakeccnu.Account{StudentId: "2023214002", Password: password},
+	)
+	ctx := context.Background()
+	for _, id := range []string{undergraduateId, "2023214002"} {
+		if _, err := svc.GetTimetable(ctx, id, password, "2023", "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cnt := svc.(keepAliver).KeepAlive(ctx); cnt != 1 || fake.Hits(fakeccnu.RouteKeepAlive) != 1 {
+		t.Fatalf("每轮最多刷新 1 个会话: %d", cnt)
+	}
+}
+
+func TestSessionKeeper(t *testing.T) {
+	cfg := service.KeepAliveConfig{Interval: 10 * time.Millisecond, ActiveWithin: time.Minute, MaxSessions: 10}
+	fake, svc := newTestServiceWith(t, testLimits, cfg, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	if _, err := svc.GetTimetable(context.Background(), undergraduateId, password, "2023", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interval 为 0 时不启动，Stop 也不会阻塞
+	idle := service.NewSessionKeeper(svc, service.KeepAliveConfig{}, logger.NewNopLogger())
+	idle.Start()
+	idle.Stop()
+
+	k := service.NewSessionKeeper(svc, cfg, logger.NewNopLogger())
+	k.Start()
+	deadline := time.Now().Add(time.Second)
+	for fake.Hits(fakeccnu.RouteKeepAlive) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("后台没有定时保活")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	k.Stop()
+	hits := fake.Hits(fakeccnu.RouteKeepAlive)
+	time.Sleep(30 * time.Millisecond)
+	if got := fake.Hits(fakeccnu.RouteKeepAlive); got != hits {
+		t.Fatalf("Stop 之后不应该再保活，多访问了 %d 次", got-hits)
+	}
+}
@@ -0,0 +1,38 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestSessionExpiryRelogin(t *testing.T) {
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	ctx := context.Background()
+
+	if _, err := svc.GetTimetable(ctx, undergraduateId, password, "2023", "1"); err != nil {
+		t.Fatal(err)
+	}
+	sso := fake.Hits(fakeccnu.RouteSSO)
+	if _, err := svc.GetTimetable(ctx, undergraduateId, password, "2023", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Hits(fakeccnu.RouteSSO); got != sso {
+		t.Fatalf("会话有效时不应该重新登录，多登录了 %d 次", got-sso)
+	}
+
+	fake.ExpireSessions()
+	if _, err := svc.GetTimetable(ctx, undergraduateId, password, "2023", "1"); err != nil {
+		t.Fatalf("会话失效后应该重新登录: %v", err)
+	}
+	if got := fake.Hits(fakeccnu.RouteSSO); got != sso+1 {
+		t.Fatalf("会话失效后应该重新登录一次，实际 %d 次", got-sso)
+	}
+
+	// 两次都拿到登录页，说明重新登录也解决不了
+	fake.Inject(fakeccnu.Fault{Route: fakeccnu.RouteTimetable, ExpireSession: true})
+	if _, err := svc.GetTimetable(ctx, undergraduateId, password, "2023", "1"); !ccnuv1.IsUnexpectedResponse(err) {
+		t.Fatalf("重新登录后仍然失效应该返回 UNEXPECTED_RESPONSE: %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"sync"
+	"time"
+)
+
+// sessionToken 服务端签发的会话令牌，绑定在一个已缓存的上游会话上，不保存密码
+type sessionToken struct {
+	studentId string
+	sess      *session
+	expireAt  time.Time
+}
+
+// tokenStore 保存签发出去的会话令牌
+type tokenStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	tokens map[string]*sessionToken
+}
+
+func newTokenStore(ttl time.Duration) *tokenStore {
+	return &tokenStore{
+		ttl:    ttl,
+		tokens: make(map[string]*sessionToken),
+	}
+}
+
+func (s *tokenStore) issue(sess *session) (domain.SessionToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return domain.SessionToken{}, err
+	}
+	token := hex.EncodeToString(b)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, v := range s.tokens {
+		if now.After(v.expireAt) {
+			delete(s.tokens, k)
+		}
+	}
+	t := &sessionToken{
+		studentId: sess.studentId,
+		sess:      sess,
+		expireAt:  now.Add(s.ttl),
+	}
+	s.tokens[token] = t
+	return domain.SessionToken{
+		Token:     token,
+		StudentId: t.studentId,
+		ExpireAt:  t.expireAt,
+	}, nil
+}
+
+func (s *tokenStore) get(token string) (sessionToken, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[token]
+	if !ok {
+		return sessionToken{}, false
+	}
+	if time.Now().After(t.expireAt) {
+		delete(s.tokens, token)
+		return sessionToken{}, false
+	}
+	return *t, true
+}
+
+func (s *tokenStore) rebind(token string, sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tokens[token]; ok {
+		t.sess = sess
+	}
+}
+
+func (s *tokenStore) revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, token)
+}
+
+type sessionTokenKey struct{}
+
+// WithSessionToken 把调用方携带的会话令牌放进 context，之后的查询会使用令牌绑定的会话，不再需要学号和密码
+func WithSessionToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionTokenKey{}, token)
+}
+
+func sessionTokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(sessionTokenKey{}).(string)
+	return token, ok && token != ""
+}
+
+func (c *ccnuService) IssueSessionToken(ctx context.Context, studentId string, password string) (domain.SessionToken, error) {
+	start := time.Now()
+	sess, cached, err := c.passwordSession(ctx, studentId, password)
+	if cached {
+		c.auditCachedLogin(ctx, studentId, start)
+	}
+	if err != nil {
+		return domain.SessionToken{}, contextError(err)
+	}
+	return c.tokens.issue(sess)
+}
+
+// RevokeSessionToken 退出登录，令牌绑定的会话也一起丢掉，不再保活
+func (c *ccnuService) RevokeSessionToken(ctx context.Context, token string) error {
+	if t, ok := c.tokens.get(token); ok {
+		c.sessions.remove(t.sess)
+	}
+	c.tokens.revoke(token)
+	return nil
+}
+
+// tokenSession 获取令牌绑定的会话，令牌过期、被吊销或者上游会话已经失效时需要重新登录。
+// 受信任的调用方保存了该学生的密码时，上游会话失效后会自动重新登录并重新绑定令牌
+func (c *ccnuService) tokenSession(ctx context.Context, token string) (*session, error) {
+	t, ok := c.tokens.get(token)
+	if !ok {
+		return nil, ccnuv1.ErrorSessionExpired("会话已过期，请重新登录")
+	}
+	if c.sessions.touch(t.sess) {
+		return t.sess, nil
+	}
+	if c.vault != nil {
+		sess, _, err := c.passwordSession(ctx, t.studentId, "")
+		if err == nil {
+			c.tokens.rebind(token, sess)
+			return sess, nil
+		}
+	}
+	c.tokens.revoke(token)
+	return nil, ccnuv1.ErrorSessionExpired("会话已过期，请重新登录")
+}
@@ -0,0 +1,82 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestSessionToken(t *testing.T) {
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password, Timetables: testTimetable})
+	ctx := context.Background()
+
+	if _, err := svc.IssueSessionToken(ctx, undergraduateId, "wrong"); !ccnuv1.IsInvalidSidOrPwd(err) {
+		t.Fatalf("密码错误不能签发令牌: %v", err)
+	}
+	token, err := svc.IssueSessionToken(ctx, undergraduateId, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token == "" || token.StudentId != undergraduateId || token.ExpireAt.IsZero() {
+		t.Fatalf("令牌有误: %+v", token)
+	}
+
+	// 带着令牌查询不用再传学号和密码，也不用重新登录
+	hits := fake.Hits(fakeccnu.RouteCASLogin)
+	tokenCtx := service.WithSessionToken(ctx, token.Token)
+	if _, err = svc.GetTimetable(tokenCtx, "", "", "2023", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Hits(fakeccnu.RouteCASLogin); got != hits {
+		t.Fatalf("带着令牌查询不应该重新登录，多请求了 %d 次 CAS", got-hits)
+	}
+
+	if err = svc.RevokeSessionToken(ctx, token.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = svc.GetTimetable(tokenCtx, "", "", "2023", "1"); !ccnuv1.IsSessionExpired(err) {
+		t.Fatalf("吊销的令牌不能再用: %v", err)
+	}
+	if _, err = svc.GetTimetable(service.WithSessionToken(ctx, "nope"), "", "", "2023", "1"); !ccnuv1.IsSessionExpired(err) {
+		t.Fatalf("不认识的令牌应该要求重新登录: %v", err)
+	}
+}
+
+func TestSessionTokenUpstreamExpired(t *testing.T) {
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password, Timetables: testTimetable})
+	ctx := context.Background()
+	trusted := service.WithTrustedCaller(ctx)
+
+	// 没有保存密码，上游会话失效后令牌也跟着失效
+	token, err := svc.IssueSessionToken(ctx, undergraduateId, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.ExpireSessions()
+	tokenCtx := service.WithSessionToken(trusted, token.Token)
+	if _, err = svc.GetTimetable(tokenCtx, "", "", "2023", "1"); !ccnuv1.IsSessionExpired(err) {
+		t.Fatalf("上游会话失效后应该要求重新登录: %v", err)
+	}
+
+	// 保存了密码时自动重新登录，令牌继续可用
+	if err = svc.SaveCredential(trusted, undergraduateId, password); err != nil {
+		t.Fatal(err)
+	}
+	if token, err = svc.IssueSessionToken(ctx, undergraduateId, password); err != nil {
+		t.Fatal(err)
+	}
+	tokenCtx = service.WithSessionToken(trusted, token.Token)
+	fake.ExpireSessions()
+	hits := fake.Hits(fakeccnu.RouteCASLogin)
+	if _, err = svc.GetTimetable(tokenCtx, "", "", "2023", "1"); err != nil {
+		t.Fatalf("保存了密码时应该自动重新登录: %v", err)
+	}
+	if fake.Hits(fakeccnu.RouteCASLogin) == hits {
+		t.Fatal("应该重新登录了 CAS")
+	}
+	if _, err = svc.GetTimetable(tokenCtx, "", "", "2023", "1"); err != nil {
+		t.Fatalf("令牌应该绑定到新的会话上: %v", err)
+	}
+}
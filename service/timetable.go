@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type OriginalTimetable struct {
+	KbList []OriginalClassItem `json:"kbList"`
+}
+
+// OriginalClassItem 课表中的一次课
+type OriginalClassItem struct {
+	Kch   string `json:"kch"`   // 课程号
+	Kcmc  string `json:"kcmc"`  // 课程名称
+	Xm    string `json:"xm"`    // 教师姓名，多个教师用逗号分隔
+	Jxbmc string `json:"jxbmc"` // 教学班名称
+	Xqj   string `json:"xqj"`   // 星期几，1-7
+	Jcs   string `json:"jcs"`   // 节次，如 1-2
+	Zcd   string `json:"zcd"`   // 周次，如 1-15周(单),2-8周(双)
+	Xqmc  string `json:"xqmc"`  // 校区
+	Cdmc  string `json:"cdmc"`  // 上课地点，如 7号楼7101
+}
+
+// GetTimetable 个人课表
+func (c *ccnuService) GetTimetable(ctx context.Context, studentId, password, year, term string) ([]domain.ClassSession, error) {
+	var data OriginalTimetable
+	err := c.doXK(ctx, studentId, password, func(sess *session) error {
+		var er error
+		data, er = c.queryTimetable(ctx, sess, year, term)
+		return er
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.ClassSession, 0, len(data.KbList))
+	for _, item := range data.KbList {
+		cs, err := convertClassItem(item)
+		if err != nil {
+			// 不能因为一门课认不出来就让学生看不到整张课表，跳过这门课
+			c.l.Warn("无法识别的课程", logger.String("kch", item.Kch), logger.String("xqj", item.Xqj),
+				logger.String("jcs", item.Jcs), logger.String("zcd", item.Zcd), logger.Error(err))
+			continue
+		}
+		res = append(res, cs)
+	}
+	return res, nil
+}
+
+func (c *ccnuService) queryTimetable(ctx context.Context, sess *session, year, term string) (OriginalTimetable, error) {
+	xnm, xqm := c.calendar.XKParams(year, term)
+	formData := url.Values{}
+	formData.Set("xnm", xnm)
+	formData.Set("xqm", xqm)
+	formData.Set("kzlx", "ck")
+
+	req, err := http.NewRequestWithContext(httpx.Idempotent(ctx), "POST", sess.system.cfg.Timetable.URL(nil), strings.NewReader(formData.Encode()))
+	if err != nil {
+		return OriginalTimetable{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+	req.Header.Set("Origin", sess.system.cfg.Timetable.Origin())
+	req.Header.Set("Accept", "application/json, text/javascript, */*; q=0.01")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+
+	resp, err := sess.client.Do(req)
+	if err != nil {
+		return OriginalTimetable{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return OriginalTimetable{}, err
+	}
+	if err = checkXKResponse(resp, body); err != nil {
+		return OriginalTimetable{}, err
+	}
+	var data OriginalTimetable
+	err = decodeXKJSON(body, &data)
+	return data, err
+}
+
+func convertClassItem(src OriginalClassItem) (domain.ClassSession, error) {
+	weekday, err := strconv.Atoi(src.Xqj)
+	if err != nil || weekday < 1 || weekday > 7 {
+		return domain.ClassSession{}, ccnuv1.ErrorUnexpectedResponse("无法识别的星期: %s", src.Xqj)
+	}
+	start, end, err := parseRange(strings.TrimSuffix(src.Jcs, "节"))
+	if err != nil {
+		return domain.ClassSession{}, ccnuv1.ErrorUnexpectedResponse("无法识别的节次: %s", src.Jcs)
+	}
+	weeks, err := parseWeeks(src.Zcd)
+	if err != nil {
+		return domain.ClassSession{}, ccnuv1.ErrorUnexpectedResponse("无法识别的周次: %s", src.Zcd)
+	}
+	building, room := splitRoom(src.Cdmc)
+	return domain.ClassSession{
+		CourseId:    src.Kch,
+		Name:        src.Kcmc,
+		Teacher:     src.Xm,
+		Class:       src.Jxbmc,
+		Weekday:     weekday,
+		StartPeriod: start,
+		EndPeriod:   end,
+		Weeks:       weeks,
+		WeeksText:   src.Zcd,
+		Campus:      src.Xqmc,
+		Building:    building,
+		Room:        room,
+	}, nil
+}
+
+// parseWeeks 解析周次，如 1-15周(单),2-8周(双),10周
+func parseWeeks(s string) ([]domain.WeekRange, error) {
+	var res []domain.WeekRange
+	for _, seg := range strings.Split(s, ",") {
+		seg = strings.TrimSpace(seg)
+		if seg == "" {
+			continue
+		}
+		parity := domain.EveryWeek
+		switch {
+		case strings.HasSuffix(seg, "(单)"):
+			parity = domain.OddWeeks
+			seg = strings.TrimSuffix(seg, "(单)")
+		case strings.HasSuffix(seg, "(双)"):
+			parity = domain.EvenWeeks
+			seg = strings.TrimSuffix(seg, "(双)")
+		}
+		start, end, err := parseRange(strings.TrimSuffix(seg, "周"))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, domain.WeekRange{Start: start, End: end, Parity: parity})
+	}
+	return res, nil
+}
+
+// parseRange 解析 1-2 或者 3 这样的范围
+func parseRange(s string) (int, int, error) {
+	first, last, found := strings.Cut(s, "-")
+	start, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return start, start, nil
+	}
+	end, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// splitRoom 把 7号楼7101 这样的上课地点拆成楼和教室，没有楼名时整个作为教室
+func splitRoom(s string) (string, string) {
+	i := strings.Index(s, "楼")
+	if i < 0 || i+len("楼") == len(s) {
+		return "", s
+	}
+	return s[:i+len("楼")], s[i+len("楼"):]
+}
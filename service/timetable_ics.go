@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/ics"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ExportTimetable 把课表导出为 iCalendar，每次课是一个按周重复的事件，不上课的周和放假的日子作为 EXDATE，
+// 调休上课的日子作为 RDATE
+func (c *ccnuService) ExportTimetable(ctx context.Context, studentId, password, year, term string) ([]byte, error) {
+	t, ok := c.calendar.term(year, term)
+	if !ok {
+		return nil, ccnuv1.ErrorUnknownTerm("校历中没有 %s 学年第 %s 学期", year, term)
+	}
+	sessions, err := c.GetTimetable(ctx, studentId, password, year, term)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]ics.Event, 0, len(sessions))
+	for _, cs := range sessions {
+		e, ok, err := c.classEvent(t, cs)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			events = append(events, e)
+		}
+	}
+	y, _ := strconv.Atoi(year)
+	cal := ics.Calendar{
+		ProdID:   "-//asynccnu//be-ccnu//CN",
+		Name:     fmt.Sprintf("%d-%d 学年第 %s 学期课表", y, y+1, term),
+		Location: shanghai,
+		Events:   events,
+	}
+	return cal.Encode(time.Now()), nil
+}
+
+// TimetableFilename 下载课表日历时的文件名
+func TimetableFilename(year, term string) string {
+	return fmt.Sprintf("timetable-%s-%s.ics", year, term)
+}
+
+// classEvent 一次课对应的重复事件，没有任何上课周时返回 false
+func (c *ccnuService) classEvent(t academicTerm, cs domain.ClassSession) (ics.Event, bool, error) {
+	weeks := classWeeks(cs.Weeks, t.info.Weeks)
+	if len(weeks) == 0 {
+		return ics.Event{}, false, nil
+	}
+	periods := c.calendar.Periods()
+	if cs.StartPeriod < 1 || cs.EndPeriod > len(periods) || cs.StartPeriod > cs.EndPeriod {
+		return ics.Event{}, false, ccnuv1.ErrorUnexpectedResponse("节次 %d-%d 不在作息时间表内", cs.StartPeriod, cs.EndPeriod)
+	}
+	// 第 week 周上这次课的日期
+	day := func(week int) time.Time {
+		return t.info.Start.AddDate(0, 0, (week-1)*7+cs.Weekday-1)
+	}
+	startClock, endClock := periods[cs.StartPeriod-1].Start, periods[cs.EndPeriod-1].End
+	start, end := atClock(day(weeks[0]), startClock), atClock(day(weeks[0]), endClock)
+	e := ics.Event{
+		UID:         classUID(t.info.Year, t.info.Term, cs),
+		Summary:     cs.Name,
+		Location:    cs.Building + cs.Room,
+		Description: fmt.Sprintf("教师：%s\n周次：%s", cs.Teacher, cs.WeeksText),
+		Start:       start,
+		End:         end,
+	}
+	last := weeks[len(weeks)-1]
+	if last != weeks[0] {
+		e.Until = atClock(day(last), startClock)
+	}
+	in := make(map[int]bool, len(weeks))
+	for _, w := range weeks {
+		in[w] = true
+	}
+	for w := weeks[0]; w <= last; w++ {
+		if _, holiday := t.holidays[day(w).Format(time.DateOnly)]; !in[w] || holiday {
+			e.ExDates = append(e.ExDates, atClock(day(w), startClock))
+		}
+	}
+	// 调休：如果被调走的那天有这次课，就在调休上课的那天补上
+	for date, as := range t.workdays {
+		for _, w := range weeks {
+			if day(w).Equal(as) {
+				d, _ := time.ParseInLocation(time.DateOnly, date, shanghai)
+				e.RDates = append(e.RDates, atClock(d, startClock))
+			}
+		}
+	}
+	sort.Slice(e.RDates, func(i, j int) bool {
+		return e.RDates[i].Before(e.RDates[j])
+	})
+	return e, true, nil
+}
+
+// classWeeks 所有上课的周，升序。教务系统里的周次可能超出校历上这学期的周数，只保留 1 到 total 周
+func classWeeks(ranges []domain.WeekRange, total int) []int {
+	set := make(map[int]struct{})
+	for _, r := range ranges {
+		for w := max(r.Start, 1); w <= min(r.End, total); w++ {
+			if r.Contains(w) {
+				set[w] = struct{}{}
+			}
+		}
+	}
+	weeks := make([]int, 0, len(set))
+	for w := range set {
+		weeks = append(weeks, w)
+	}
+	sort.Ints(weeks)
+	return weeks
+}
+
+// atClock day 那天的 clock 时刻，clock 在加载校历时已经校验过格式
+func atClock(day time.Time, clock string) time.Time {
+	t, _ := time.Parse("15:04", clock)
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+}
+
+// classUID 同一次课每次导出的 UID 都一样，重新导入时日历应用会更新而不是重复添加
+func classUID(year, term string, cs domain.ClassSession) string {
+	h := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%s|%d|%d|%d|%s",
+		year, term, cs.CourseId, cs.Class, cs.Weekday, cs.StartPeriod, cs.EndPeriod, cs.WeeksText)))
+	return hex.EncodeToString(h[:12]) + "@be-ccnu"
+}
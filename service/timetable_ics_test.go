@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"strings"
+	"testing"
+)
+
+func TestExportTimetable(t *testing.T) {
+	_, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password, Timetables: testTimetable})
+	data, err := svc.ExportTimetable(context.Background(), undergraduateId, password, "2023", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := string(data)
+	for _, line := range strings.Split(doc, "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("超过 75 个字节的行没有折行: %q", line)
+		}
+	}
+	// 折行的续行以一个空格开头，展开后再检查内容
+	doc = strings.ReplaceAll(doc, "\r\n ", "")
+	for _, want := range []string{
+		// 单双周交错的课：10 月 2 日是国庆，第 10、12、14 周没课
+		"DTSTART;TZID=Asia/Shanghai:20230904T080000",
+		"EXDATE;TZID=Asia/Shanghai:20231002T080000,20231106T080000,20231120T080000,20231204T080000",
+		// 周五的课：国庆的两个周五停课，10 月 7 日补 10 月 6 日的课
+		"DTSTART;TZID=Asia/Shanghai:20230908T101000",
+		"EXDATE;TZID=Asia/Shanghai:20230929T101000,20231006T101000",
+		"RDATE;TZID=Asia/Shanghai:20231007T101000",
+		`DESCRIPTION:教师：李四\,王五\,赵六\,钱七\,孙八\,周九\,吴十\,郑十一\n周次：1-18周`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("日历里缺少 %s\n%s", want, doc)
+		}
+	}
+}
+
+func TestExportTimetableClampsWeeks(t *testing.T) {
+	timetables := map[string][]service.OriginalClassItem{
+		"2023-1": {
+			// 这学期只有 18 周，第 19 周之后的不导出
+			{Kch: "CS201", Kcmc: "操作系统", Xm: "张三", Jxbmc: "操作系统-01", Xqj: "3", Jcs: "1-2", Zcd: "1-25周", Xqmc: "本部", Cdmc: "7号楼7102"},
+			{Kch: "CS202", Kcmc: "编译原理", Xm: "张三", Jxbmc: "编译原理-01", Xqj: "4", Jcs: "1-2", Zcd: "20-22周", Xqmc: "本部", Cdmc: "7号楼7103"},
+		},
+	}
+	_, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password, Timetables: timetables})
+	data, err := svc.ExportTimetable(context.Background(), undergraduateId, password, "2023", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := strings.ReplaceAll(string(data), "\r\n ", "")
+	// 第 18 周的周三是 2024 年 1 月 3 日
+	if !strings.Contains(doc, "RRULE:FREQ=WEEKLY;UNTIL=20240103T000000Z") {
+		t.Errorf("重复应该截止到第 18 周:\n%s", doc)
+	}
+	if strings.Contains(doc, "编译原理") {
+		t.Errorf("不在这学期的课不应该导出:\n%s", doc)
+	}
+}
@@ -0,0 +1,48 @@
+package service_test
+
+import (
+	"context"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"reflect"
+	"testing"
+)
+
+var testTimetable = map[string][]service.OriginalClassItem{
+	"2023-1": {
+		{Kch: "CS101", Kcmc: "数据结构", Xm: "张三", Jxbmc: "数据结构-01", Xqj: "1", Jcs: "1-2", Zcd: "1-15周(单),2-8周(双)", Xqmc: "本部", Cdmc: "7号楼7101"},
+		{
+			Kch: "PE101", Kcmc: "体育", Xm: "李四,王五,赵六,钱七,孙八,周九,吴十,郑十一", Jxbmc: "体育-03", Xqj: "5", Jcs: "3-4节",
+			Zcd: "1-18周", Xqmc: "本部", Cdmc: "东区操场",
+		},
+		// 周次认不出来，跳过这门课
+		{Kch: "MA101", Kcmc: "高等数学", Xm: "陈一", Jxbmc: "高等数学-02", Xqj: "3", Jcs: "5-6", Zcd: "第一周", Xqmc: "本部", Cdmc: "9号楼9201"},
+	},
+}
+
+func TestGetTimetable(t *testing.T) {
+	_, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password, Timetables: testTimetable})
+	sessions, err := svc.GetTimetable(context.Background(), undergraduateId, password, "2023", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []domain.ClassSession{
+		{
+			CourseId: "CS101", Name: "数据结构", Teacher: "张三", Class: "数据结构-01", Weekday: 1, StartPeriod: 1, EndPeriod: 2,
+			Weeks: []domain.WeekRange{
+				{Start: 1, End: 15, Parity: domain.OddWeeks},
+				{Start: 2, End: 8, Parity: domain.EvenWeeks},
+			},
+			WeeksText: "1-15周(单),2-8周(双)", Campus: "本部", Building: "7号楼", Room: "7101",
+		},
+		{
+			CourseId: "PE101", Name: "体育", Teacher: "李四,王五,赵六,钱七,孙八,周九,吴十,郑十一", Class: "体育-03", Weekday: 5, StartPeriod: 3, EndPeriod: 4,
+			Weeks:     []domain.WeekRange{{Start: 1, End: 18, Parity: domain.EveryWeek}},
+			WeeksText: "1-18周", Campus: "本部", Room: "东区操场",
+		},
+	}
+	if !reflect.DeepEqual(sessions, want) {
+		t.Fatalf("课表有误:\n got %+v\nwant %+v", sessions, want)
+	}
+}
@@ -3,28 +3,100 @@ package service
 import (
 	"context"
 	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
 	"github.com/asynccnu/be-ccnu/pkg/logger"
 	"time"
 )
 
 type CCNUService interface {
 	Login(ctx context.Context, studentId string, password string) (bool, error)
+	// StartLogin 开始两阶段登录，CAS 要求验证码时返回验证码挑战，登录成功时返回 nil
+	StartLogin(ctx context.Context, studentId string, password string) (*domain.LoginChallenge, error)
+	// FinishLogin 提交验证码完成登录，验证码错误时返回新的验证码挑战
+	FinishLogin(ctx context.Context, handle string, captcha string) (*domain.LoginChallenge, error)
 	GetSelfCourseList(ctx context.Context, studentId, password, year, term string) ([]domain.Course, error)
+	// GetTimetable 个人课表，term 为 1、2、3
+	GetTimetable(ctx context.Context, studentId, password, year, term string) ([]domain.ClassSession, error)
+	// ExportTimetable 把课表导出为 iCalendar 文档
+	ExportTimetable(ctx context.Context, studentId, password, year, term string) ([]byte, error)
+	// GetExams 个人考试安排，term 为 1、2、3
+	GetExams(ctx context.Context, studentId, password, year, term string) ([]domain.Exam, error)
+	// SearchFreeClassrooms 当前学期的空闲教室，不需要账号，没有可借用的会话时才用调用方的账号
+	SearchFreeClassrooms(ctx context.Context, studentId, password string, q domain.FreeClassroomQuery) ([]domain.Classroom, error)
+	// GetCurrentTerm 校历中当前的学期，假期中是下一个学期
+	GetCurrentTerm(ctx context.Context) (domain.Term, error)
+	// GetWeekOf date 是第几周星期几，是否放假或者调休
+	GetWeekOf(ctx context.Context, date time.Time) (domain.CalendarDay, error)
 	// GetSelfGradeList 这个是只能获取总分，没有聚合平时成绩等细节，现在主要用于准确获取个人历史课程
 	GetSelfGradeList(ctx context.Context, studentId, password, year, term string) ([]domain.Grade, error)
 	// GetAllDetailOfGrade 获取所有成绩的所有细节
 	GetDetailOfGradeList(ctx context.Context, studentId string, password string, year string, term string) ([]domain.Grade, error)
-	GetCCNUCookie(ctx context.Context, studentId string, password string) (string, error)
+	// GetCCNUCookie 获取 CAS 和教务系统的全部 Cookie，按上游系统分组
+	GetCCNUCookie(ctx context.Context, studentId string, password string) ([]domain.SystemCookies, error)
+	// IssueSessionToken 登录并签发会话令牌，之后的查询可以用 WithSessionToken 携带令牌代替学号和密码
+	IssueSessionToken(ctx context.Context, studentId string, password string) (domain.SessionToken, error)
+	RevokeSessionToken(ctx context.Context, token string) error
+	// SaveCredential 校验并加密保存密码，之后只传学号的请求会使用保存的密码登录
+	SaveCredential(ctx context.Context, studentId string, password string) error
+	DeleteCredential(ctx context.Context, studentId string) error
+	// ReEncryptCredentials 密钥轮换后，用新的主密钥重新加密保存的密码
+	ReEncryptCredentials(ctx context.Context) (int, error)
+	// GetServiceCookies 用同一个 CAS 登录会话依次登录 services 里的校内系统，按系统返回 Cookie。
+	// services 为空时登录所有已知的系统
+	GetServiceCookies(ctx context.Context, studentId, password string, services []string) ([]domain.SystemCookies, error)
+	// ListLoginAttempts 按时间倒序查询 [start, end) 之间的登录审计记录，studentId 为空时查所有学号
+	ListLoginAttempts(ctx context.Context, studentId string, start, end time.Time, offset, limit int) ([]domain.LoginAttempt, error)
+	// ListEgressStats 各个出口的健康状态和请求数
+	ListEgressStats(ctx context.Context) []domain.EgressStats
+	// GetUpstreamStatus 后台探测到的 CAS 和教务系统的可用性
+	GetUpstreamStatus(ctx context.Context) []domain.UpstreamStatus
 }
 
 type ccnuService struct {
 	timeout time.Duration
-	l       logger.Logger
+	// egress 出口池，每个会话创建时从中选定一个出口
+	egress *httpx.EgressPool
+	// resilience 按上游主机熔断，重试失败的幂等请求
+	resilience    *httpx.Resilience
+	upstream      UpstreamConfig
+	undergraduate *zfSystem
+	graduate      *zfSystem
+	sessions      *sessionCache
+	pendings      *pendingLoginStore
+	tokens        *tokenStore
+	// classrooms 空闲教室的查询结果对所有人都一样，按查询条件缓存
+	classrooms *classroomCache
+	// vault 没有配置加密密钥时为 nil，此时不支持只传学号
+	vault     *CredentialVault
+	limiter   *LoginLimiter
+	keepAlive KeepAliveConfig
+	audit     *LoginAuditor
+	prober    *UpstreamProber
+	calendar  *AcademicCalendar
+	l         logger.Logger
 }
 
-func NewCCNUService(l logger.Logger) CCNUService {
+func NewCCNUService(upstream UpstreamConfig, egress *httpx.EgressPool, resilience *httpx.Resilience, vault *CredentialVault, limiter *LoginLimiter,
+	keepAlive KeepAliveConfig, audit *LoginAuditor, prober *UpstreamProber, calendar *AcademicCalendar, l logger.Logger) CCNUService {
 	return &ccnuService{
-		timeout: time.Second * 5,
-		l:       l,
+		timeout:       time.Second * 5,
+		egress:        egress,
+		resilience:    resilience,
+		upstream:      upstream,
+		undergraduate: newZFSystem("jwglxt", upstream.Undergraduate),
+		graduate:      newZFSystem("yjsxt", upstream.Graduate),
+		// 空闲教室只在有人借用场地或者调课时变化，不需要很及时
+		classrooms: newClassroomCache(time.Minute * 10),
+		// 教务系统大约 30 分钟无操作会话失效，这里留一些余量
+		sessions:  newSessionCache(time.Minute * 20),
+		pendings:  newPendingLoginStore(time.Minute * 5),
+		tokens:    newTokenStore(time.Hour * 24),
+		vault:     vault,
+		limiter:   limiter,
+		keepAlive: keepAlive,
+		audit:     audit,
+		prober:    prober,
+		calendar:  calendar,
+		l:         l,
 	}
 }
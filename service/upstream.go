@@ -0,0 +1,104 @@
+package service
+
+import (
+	"net/url"
+)
+
+// Endpoint 一个上游地址
+type Endpoint struct {
+	Scheme string `mapstructure:"scheme"`
+	Host   string `mapstructure:"host"`
+	Path   string `mapstructure:"path"`
+	// Gnmkdm 正方教务系统的功能模块代码，不为空时作为 gnmkdm 参数带上
+	Gnmkdm string `mapstructure:"gnmkdm"`
+}
+
+// URL 拼出完整地址，query 会和 gnmkdm 一起放在查询参数里
+func (e Endpoint) URL(query url.Values) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if e.Gnmkdm != "" {
+		q.Set("gnmkdm", e.Gnmkdm)
+	}
+	u := url.URL{
+		Scheme:   e.Scheme,
+		Host:     e.Host,
+		Path:     e.Path,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// Origin 用作 Origin 请求头
+func (e Endpoint) Origin() string {
+	u := url.URL{Scheme: e.Scheme, Host: e.Host}
+	return u.String()
+}
+
+// ZFSystemConfig 一套正方教务系统的各个接口地址
+type ZFSystemConfig struct {
+	// SSO 教务系统在 CAS 注册的 service 地址
+	SSO             Endpoint `mapstructure:"sso"`
+	Course          Endpoint `mapstructure:"course"`
+	Grade           Endpoint `mapstructure:"grade"`
+	GradeDetail     Endpoint `mapstructure:"gradeDetail"`
+	GradePage       Endpoint `mapstructure:"gradePage"`
+	GradeDetailPage Endpoint `mapstructure:"gradeDetailPage"`
+	// KeepAlive 后台保活时访问的页面，越轻越好
+	KeepAlive Endpoint `mapstructure:"keepAlive"`
+	Timetable Endpoint `mapstructure:"timetable"`
+	Exam      Endpoint `mapstructure:"exam"`
+	// FreeClassroom 场地借用里的空闲教室查询
+	FreeClassroom Endpoint `mapstructure:"freeClassroom"`
+	// Static 不需要登录的静态页面，用于探测教务系统是否可用
+	Static Endpoint `mapstructure:"static"`
+}
+
+// UpstreamConfig 所有上游地址，测试或者预发环境可以把它们指向镜像或者本地的替身服务
+type UpstreamConfig struct {
+	CASLogin      Endpoint       `mapstructure:"casLogin"`
+	Undergraduate ZFSystemConfig `mapstructure:"undergraduate"`
+	Graduate      ZFSystemConfig `mapstructure:"graduate"`
+	// Services 其它接入了 CAS 的校内系统，键是系统名，值是系统在 CAS 注册的 service 地址
+	Services map[string]Endpoint `mapstructure:"services"`
+}
+
+// DefaultUpstreamConfig 学校线上环境的地址
+func DefaultUpstreamConfig() UpstreamConfig {
+	return UpstreamConfig{
+		CASLogin: Endpoint{Scheme: "https", Host: "account.ccnu.edu.cn", Path: "/cas/login"},
+		Undergraduate: ZFSystemConfig{
+			SSO:             Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/sso/pziotlogin"},
+			Course:          Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/xkcx/xkmdcx_cxXkmdcxIndex.html", Gnmkdm: "N255010"},
+			Grade:           Endpoint{Scheme: "https", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/cjcx/cjcx_cxXsgrcj.html", Gnmkdm: "N305005"},
+			GradeDetail:     Endpoint{Scheme: "https", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/cjcx/cjcx_cxXsXmcjList.html", Gnmkdm: "N305007"},
+			GradePage:       Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/cjcx/cjcx_cxDgXscj.html", Gnmkdm: "N305005"},
+			GradeDetailPage: Endpoint{Scheme: "https", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/cjcx/cjcx_cxDgXsxmcj.html", Gnmkdm: "N305007"},
+			KeepAlive:       Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/xtgl/index_cxYhxxIndex.html", Gnmkdm: "index"},
+			Timetable:       Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/kbcx/xskbcx_cxXsgrkb.html", Gnmkdm: "N2151"},
+			Exam:            Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/kwgl/kscx_cxXsksxxIndex.html", Gnmkdm: "N358105"},
+			FreeClassroom:   Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/cdjy/cdjy_cxKxcdlb.html", Gnmkdm: "N2155"},
+			Static:          Endpoint{Scheme: "http", Host: "xk.ccnu.edu.cn", Path: "/jwglxt/xtgl/login_slogin.html"},
+		},
+		Graduate: ZFSystemConfig{
+			SSO:             Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/sso/zfiotlogin"},
+			Course:          Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/xkcx/xkmdcx_cxXkmdcxIndex.html", Gnmkdm: "N255010"},
+			Grade:           Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/cjcx/cjcx_cxXsgrcj.html", Gnmkdm: "N305005"},
+			GradeDetail:     Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/cjcx/cjcx_cxXsXmcjList.html", Gnmkdm: "N305007"},
+			GradePage:       Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/cjcx/cjcx_cxDgXscj.html", Gnmkdm: "N305005"},
+			GradeDetailPage: Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/cjcx/cjcx_cxDgXsxmcj.html", Gnmkdm: "N305007"},
+			KeepAlive:       Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/xtgl/index_cxYhxxIndex.html", Gnmkdm: "index"},
+			Timetable:       Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/kbcx/xskbcx_cxXsgrkb.html", Gnmkdm: "N2151"},
+			Exam:            Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/kwgl/kscx_cxXsksxxIndex.html", Gnmkdm: "N358105"},
+			FreeClassroom:   Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/cdjy/cdjy_cxKxcdlb.html", Gnmkdm: "N2155"},
+			Static:          Endpoint{Scheme: "https", Host: "grd.ccnu.edu.cn", Path: "/yjsxt/xtgl/login_slogin.html"},
+		},
+		Services: map[string]Endpoint{
+			"portal":  {Scheme: "http", Host: "one.ccnu.edu.cn", Path: "/cas/login_portal"},
+			"library": {Scheme: "http", Host: "kjyy.ccnu.edu.cn", Path: "/loginall.aspx"},
+			"ecard":   {Scheme: "http", Host: "ecard.ccnu.edu.cn", Path: "/cas/login"},
+		},
+	}
+}
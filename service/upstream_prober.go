@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/asynccnu/be-ccnu/domain"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/pkg/metricsx"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ProbeConfig 上游探测配置
+type ProbeConfig struct {
+	// Interval 每隔多久探测一轮，为 0 时不启动
+	Interval time.Duration
+	// Timeout 单次探测的超时时间，不大于 0 时使用 5 秒
+	Timeout time.Duration
+	// History 每个上游保留最近多少次探测结果
+	History int
+	// FailureThreshold 一个主机连续多少轮探测都失败才打开它的熔断器，不大于 0 时使用 3。
+	// 一次网络抖动就熔断会把真实请求挡在外面整整一个 OpenDuration
+	FailureThreshold int
+}
+
+type probeTarget struct {
+	name string
+	url  string
+	host string
+}
+
+type probeState struct {
+	target  probeTarget
+	since   time.Time
+	history []domain.ProbeResult
+}
+
+// UpstreamProber 定期探测 CAS 和教务系统是否可用。
+// 同一个主机上的页面连续 FailureThreshold 轮全部探测失败时会直接打开这个主机的熔断器，恢复后关闭。
+// 只关闭探测自己打开的熔断器，真实请求失败打开的熔断器交给熔断器自己的半开探测
+type UpstreamProber struct {
+	cfg        ProbeConfig
+	egress     *httpx.EgressPool
+	resilience *httpx.Resilience
+	l          logger.Logger
+
+	mu     sync.RWMutex
+	states []*probeState
+	// failures 每个主机连续探测失败的轮数
+	failures map[string]int
+	// tripped 被探测打开了熔断器、还没有恢复的主机
+	tripped map[string]bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewUpstreamProber(upstream UpstreamConfig, egress *httpx.EgressPool, resilience *httpx.Resilience,
+	cfg ProbeConfig, l logger.Logger) *UpstreamProber {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second * 5
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	targets := []probeTarget{
+		{name: "cas", url: upstream.CASLogin.URL(nil)},
+		{name: "jwglxt_sso", url: upstream.Undergraduate.SSO.URL(nil)},
+		{name: "jwglxt", url: upstream.Undergraduate.Static.URL(nil)},
+	}
+	p := &UpstreamProber{
+		cfg:        cfg,
+		egress:     egress,
+		resilience: resilience,
+		l:          l,
+		failures:   make(map[string]int),
+		tripped:    make(map[string]bool),
+	}
+	for _, t := range targets {
+		if u, err := url.Parse(t.url); err == nil {
+			t.host = u.Host
+		}
+		p.states = append(p.states, &probeState{target: t})
+	}
+	return p
+}
+
+// Probe 探测一轮所有上游
+func (p *UpstreamProber) Probe(ctx context.Context) {
+	results := make([]domain.ProbeResult, len(p.states))
+	var wg sync.WaitGroup
+	for i, st := range p.states {
+		wg.Add(1)
+		go func(i int, t probeTarget) {
+			defer wg.Done()
+			results[i] = p.probe(ctx, t)
+		}(i, st.target)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	p.mu.Lock()
+	// 主机上只要有一个页面可用就认为主机可用
+	hosts := make(map[string]bool)
+	for i, st := range p.states {
+		res := results[i]
+		if len(st.history) == 0 || st.history[0].Up != res.Up {
+			st.since = res.Time
+			if len(st.history) > 0 {
+				p.l.Warn("上游状态变化", logger.String("upstream", st.target.name),
+					logger.Any("up", res.Up), logger.String("error", res.Error))
+			}
+		}
+		st.history = append([]domain.ProbeResult{res}, st.history...)
+		if len(st.history) > max(p.cfg.History, 1) {
+			st.history = st.history[:max(p.cfg.History, 1)]
+		}
+		hosts[st.target.host] = hosts[st.target.host] || res.Up
+	}
+	for host, up := range hosts {
+		if !up {
+			p.failures[host]++
+			if p.failures[host] >= p.cfg.FailureThreshold {
+				p.resilience.Trip(host)
+				p.tripped[host] = true
+			}
+			continue
+		}
+		delete(p.failures, host)
+		if p.tripped[host] {
+			p.resilience.Reset(host)
+			delete(p.tripped, host)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// probe 请求一次页面，不跟随重定向，没有返回 5xx 就认为可用
+func (p *UpstreamProber) probe(ctx context.Context, t probeTarget) domain.ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+	start := time.Now()
+	res := domain.ProbeResult{Time: start}
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", t.url, nil)
+		if err != nil {
+			return err
+		}
+		client := &http.Client{
+			// 探测不走熔断，否则熔断打开后就探测不到上游恢复了
+			Transport: p.egress.Peek(),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		return nil
+	}()
+	res.Latency = time.Since(start)
+	res.Up = err == nil
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func (p *UpstreamProber) Status() []domain.UpstreamStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	res := make([]domain.UpstreamStatus, 0, len(p.states))
+	for _, st := range p.states {
+		status := domain.UpstreamStatus{
+			Name:    st.target.name,
+			URL:     st.target.url,
+			Since:   st.since,
+			History: append([]domain.ProbeResult(nil), st.history...),
+		}
+		if len(st.history) > 0 {
+			last := st.history[0]
+			status.Up, status.LastCheck, status.Latency = last.Up, last.Time, last.Latency
+			ok := 0
+			for _, h := range st.history {
+				if h.Up {
+					ok++
+				}
+			}
+			status.Availability = float64(ok) / float64(len(st.history))
+		}
+		res = append(res, status)
+	}
+	return res
+}
+
+// Collect 输出上游是否可用和最近一次探测的耗时
+func (p *UpstreamProber) Collect(w *metricsx.Writer) {
+	var up, latency, availability []metricsx.Sample
+	for _, st := range p.Status() {
+		if st.LastCheck.IsZero() {
+			continue
+		}
+		labels := map[string]string{"upstream": st.Name}
+		v := 0.0
+		if st.Up {
+			v = 1
+		}
+		up = append(up, metricsx.Sample{Labels: labels, Value: v})
+		latency = append(latency, metricsx.Sample{Labels: labels, Value: st.Latency.Seconds()})
+		availability = append(availability, metricsx.Sample{Labels: labels, Value: st.Availability})
+	}
+	w.Gauge("ccnu_upstream_up", "上游最近一次探测是否可用", up...)
+	w.Gauge("ccnu_upstream_probe_latency_seconds", "上游最近一次探测的耗时", latency...)
+	w.Gauge("ccnu_upstream_availability", "上游最近若干次探测中成功的比例", availability...)
+}
+
+// Start 立即探测一轮，之后按 Interval 定期探测，Interval 不大于 0 时不启动
+func (p *UpstreamProber) Start() {
+	if p.cfg.Interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		p.Probe(ctx)
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.Probe(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (p *UpstreamProber) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	<-p.done
+}
+
+func (c *ccnuService) GetUpstreamStatus(ctx context.Context) []domain.UpstreamStatus {
+	return c.prober.Status()
+}
@@ -0,0 +1,108 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"github.com/asynccnu/be-ccnu/pkg/httpx"
+	"github.com/asynccnu/be-ccnu/pkg/logger"
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUpstreamProber(t *testing.T) {
+	fake := fakeccnu.NewServer()
+	defer fake.Close()
+	l := logger.NewNopLogger()
+	egress, err := httpx.NewEgressPool(httpx.TransportConfig{}, httpx.EgressPoolConfig{}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resilience := httpx.NewResilience(httpx.ResilienceConfig{FailureThreshold: 5, OpenDuration: time.Hour})
+	// Timeout 没有配置时使用默认值，而不是每次探测都立即超时
+	prober := service.NewUpstreamProber(fake.Upstream(), egress, resilience, service.ProbeConfig{History: 10, FailureThreshold: 2}, l)
+	ctx := context.Background()
+
+	// blocked 真实请求是否被熔断挡住
+	client := &http.Client{Transport: resilience.Wrap(egress.Pick())}
+	static := fake.Upstream().Undergraduate.Static.URL(nil)
+	blocked := func() bool {
+		resp, err := client.Get(static)
+		if err != nil {
+			return errors.Is(err, httpx.ErrCircuitOpen)
+		}
+		resp.Body.Close()
+		return false
+	}
+
+	prober.Probe(ctx)
+	for _, st := range prober.Status() {
+		if !st.Up {
+			t.Fatalf("%s 应该是可用的: %+v", st.Name, st.History)
+		}
+	}
+
+	fake.Inject(fakeccnu.Fault{Status: http.StatusServiceUnavailable})
+	prober.Probe(ctx)
+	fake.ClearFaults()
+	if blocked() {
+		t.Fatal("只失败了一轮，不应该熔断")
+	}
+
+	fake.Inject(fakeccnu.Fault{Status: http.StatusServiceUnavailable})
+	prober.Probe(ctx)
+	prober.Probe(ctx)
+	fake.ClearFaults()
+	if !blocked() {
+		t.Fatal("连续失败两轮之后应该熔断")
+	}
+	for _, st := range prober.Status() {
+		if st.Up || st.Availability != 0.25 {
+			t.Fatalf("%s 的状态有误: up=%v availability=%v", st.Name, st.Up, st.Availability)
+		}
+	}
+
+	prober.Probe(ctx)
+	if blocked() {
+		t.Fatal("上游恢复后应该关闭探测打开的熔断器")
+	}
+}
+
+func TestUpstreamProberLeavesOtherBreakers(t *testing.T) {
+	fake := fakeccnu.NewServer()
+	defer fake.Close()
+	l := logger.NewNopLogger()
+	egress, err := httpx.NewEgressPool(httpx.TransportConfig{}, httpx.EgressPoolConfig{}, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resilience := httpx.NewResilience(httpx.ResilienceConfig{FailureThreshold: 1, OpenDuration: time.Hour})
+	prober := service.NewUpstreamProber(fake.Upstream(), egress, resilience, service.ProbeConfig{Timeout: time.Second}, l)
+
+	// 真实请求失败打开的熔断器，探测成功也不去关闭
+	client := &http.Client{Transport: resilience.Wrap(egress.Pick())}
+	static := fake.Upstream().Undergraduate.Static.URL(nil)
+	fake.Inject(fakeccnu.Fault{Route: fakeccnu.RouteStatic, Times: 1, Status: http.StatusBadGateway})
+	if resp, err := client.Get(static); err == nil {
+		resp.Body.Close()
+	}
+	prober.Probe(context.Background())
+	if _, err = client.Get(static); !errors.Is(err, httpx.ErrCircuitOpen) {
+		t.Fatalf("不是探测打开的熔断器不应该被关闭: %v", err)
+	}
+}
+
+func TestGetUpstreamStatus(t *testing.T) {
+	_, svc := newTestService(t)
+	statuses := svc.GetUpstreamStatus(context.Background())
+	if len(statuses) != 3 {
+		t.Fatalf("应该有 CAS 和教务系统的 3 个探测目标: %+v", statuses)
+	}
+	for _, st := range statuses {
+		if !st.LastCheck.IsZero() {
+			t.Fatalf("还没有探测过: %+v", st)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"net/http"
+	"strings"
+)
+
+// xkPage 教务系统查询接口返回 HTML 而不是 JSON 时，页面的类型
+type xkPage int
+
+const (
+	xkPageUnknown xkPage = iota
+	// xkPageLogin CAS 或者教务系统自己的登录页，会话已经失效
+	xkPageLogin
+	xkPageMaintenance
+	// xkPageEvaluation 没有完成评教，教务系统不让查成绩
+	xkPageEvaluation
+	xkPagePermissionDenied
+)
+
+// 各类页面的特征文字，按顺序匹配，登录页优先，避免登录页上的公告被误判
+var xkPageMarkers = []struct {
+	page    xkPage
+	markers []string
+}{
+	{xkPageLogin, []string{`name="lt"`, `name="execution"`, "login_slogin", `id="yhm"`, "统一身份认证"}},
+	{xkPageMaintenance, []string{"系统维护", "维护中", "暂停服务", "系统升级"}},
+	{xkPageEvaluation, []string{"评教", "教学评价", "学生评价"}},
+	{xkPagePermissionDenied, []string{"无权访问", "没有权限", "无功能权限", "权限不足"}},
+}
+
+// isHTML 查询接口正常应该返回 JSON
+func isHTML(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("<"))
+}
+
+func classifyXKPage(body []byte) xkPage {
+	s := string(body)
+	for _, m := range xkPageMarkers {
+		for _, marker := range m.markers {
+			if strings.Contains(s, marker) {
+				return m.page
+			}
+		}
+	}
+	return xkPageUnknown
+}
+
+// checkXKResponse 在解析 JSON 之前检查教务系统的响应。
+// 会话失效时返回 errSessionExpired，由 doXK 重新登录；维护、需要评教、没有权限等情况返回对应的错误
+func checkXKResponse(resp *http.Response, body []byte) error {
+	if err := checkXKRedirect(resp); err != nil {
+		return err
+	}
+	html := isHTML(body)
+	if html {
+		switch classifyXKPage(body) {
+		case xkPageLogin:
+			return errSessionExpired
+		case xkPageMaintenance:
+			return ccnuv1.ErrorXkMaintenance("教务系统正在维护，请稍后再试")
+		case xkPageEvaluation:
+			return ccnuv1.ErrorEvaluationRequired("请先在教务系统完成评教")
+		case xkPagePermissionDenied:
+			return ccnuv1.ErrorPermissionDenied("教务系统没有开放这项查询")
+		}
+	}
+	// 重试之后仍然失败
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ccnuv1.ErrorUpstreamUnavailable("教务系统暂时不可用，请稍后再试")
+	}
+	if html {
+		return ccnuv1.ErrorUnexpectedResponse("教务系统返回了无法识别的页面")
+	}
+	return nil
+}
+
+// checkXKRedirect 会话失效后会被重定向回 CAS 或者教务系统自己的登录页
+func checkXKRedirect(resp *http.Response) error {
+	if resp.Request != nil && resp.Request.URL != nil {
+		path := resp.Request.URL.Path
+		if strings.Contains(path, "/cas/login") || strings.Contains(path, "login_slogin") {
+			return errSessionExpired
+		}
+	}
+	return nil
+}
+
+// decodeXKJSON 解析查询接口返回的 JSON，格式不对时返回 UNEXPECTED_RESPONSE
+func decodeXKJSON(body []byte, v any) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return ccnuv1.ErrorUnexpectedResponse("教务系统返回的数据无法解析: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"context"
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/service/fakeccnu"
+	"testing"
+)
+
+func TestXKHTMLResponses(t *testing.T) {
+	cases := []struct {
+		name   string
+		route  fakeccnu.Route
+		status int
+		body   string
+		is     func(error) bool
+	}{
+		{"维护", fakeccnu.RouteTimetable, 0, fakeccnu.MaintenancePage, ccnuv1.IsXkMaintenance},
+		{"需要评教", fakeccnu.RouteGrade, 0, fakeccnu.EvaluationPage, ccnuv1.IsEvaluationRequired},
+		{"没有权限", fakeccnu.RouteTimetable, 0, fakeccnu.PermissionDeniedPage, ccnuv1.IsPermissionDenied},
+		{"无法识别的页面", fakeccnu.RouteTimetable, 0, fakeccnu.ErrorPage, ccnuv1.IsUnexpectedResponse},
+		{"网关错误", fakeccnu.RouteTimetable, 502, "Bad Gateway", ccnuv1.IsUpstreamUnavailable},
+		{"不是 JSON", fakeccnu.RouteTimetable, 0, "{", ccnuv1.IsUnexpectedResponse},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+			fake.Inject(fakeccnu.Fault{Route: c.route, Status: c.status, Body: c.body})
+			var err error
+			if c.route == fakeccnu.RouteGrade {
+				_, err = svc.GetSelfGradeList(context.Background(), undergraduateId, password, "2023", "1")
+			} else {
+				_, err = svc.GetTimetable(context.Background(), undergraduateId, password, "2023", "1")
+			}
+			if !c.is(err) {
+				t.Fatalf("错误类型有误: %v", err)
+			}
+		})
+	}
+}
+
+func TestXKLoginPageMeansSessionExpired(t *testing.T) {
+	fake, svc := newTestService(t, fakeccnu.Account{StudentId: undergraduateId, Password: password})
+	ctx := context.Background()
+	if ok, err := svc.Login(ctx, undergraduateId, password); !ok || err != nil {
+		t.Fatal(err)
+	}
+	sso := fake.Hits(fakeccnu.RouteSSO)
+	// 教务系统有时直接返回登录页而不是跳转
+	fake.Inject(fakeccnu.Fault{
+		Route: fakeccnu.RouteTimetable,
+		Times: 1,
+		Body:  `<html><body><form id="fm1"><input type="hidden" name="lt" value="LT-1"/></form></body></html>`,
+	})
+	if _, err := svc.GetTimetable(ctx, undergraduateId, password, "2023", "1"); err != nil {
+		t.Fatalf("拿到登录页后应该重新登录: %v", err)
+	}
+	if got := fake.Hits(fakeccnu.RouteSSO); got != sso+1 {
+		t.Fatalf("应该重新登录一次，实际 %d 次", got-sso)
+	}
+}
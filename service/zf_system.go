@@ -0,0 +1,46 @@
+package service
+
+import (
+	ccnuv1 "github.com/asynccnu/be-api/gen/proto/ccnu/v1"
+	"github.com/asynccnu/be-ccnu/pkg/identity"
+)
+
+// zfSystem 学校部署的一套正方教务系统，本科生使用 xk.ccnu.edu.cn/jwglxt，研究生使用 grd.ccnu.edu.cn/yjsxt，
+// 两套系统的接口路径和返回格式基本一致，只是部署的地址不同
+type zfSystem struct {
+	name string
+	cfg  ZFSystemConfig
+}
+
+func newZFSystem(name string, cfg ZFSystemConfig) *zfSystem {
+	return &zfSystem{
+		name: name,
+		cfg:  cfg,
+	}
+}
+
+// systemOf 根据学号选择对应的教务系统
+func (c *ccnuService) systemOf(studentId string) (*zfSystem, error) {
+	id, err := parseStudentId(studentId)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case id.Kind == identity.Undergraduate:
+		return c.undergraduate, nil
+	case id.IsGraduate():
+		return c.graduate, nil
+	default:
+		// 教职工没有教务系统，账号密码本身没有问题
+		return nil, ccnuv1.ErrorUnsupportedIdentity("%s 账号没有教务系统，不支持这项查询", id.Kind)
+	}
+}
+
+// parseStudentId 解析并校验学号
+func parseStudentId(studentId string) (identity.Identity, error) {
+	id, err := identity.Parse(studentId)
+	if err != nil {
+		return identity.Identity{}, ccnuv1.ErrorInvalidSidOrPwd("学号格式错误")
+	}
+	return id, nil
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"github.com/asynccnu/be-ccnu/service"
+	"github.com/go-kratos/kratos/v2/errors"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+	"net/http"
+	"strconv"
+)
+
+// TimetableHandler 课表日历的 HTTP 下载，方便直接在手机日历里导入或者订阅
+type TimetableHandler struct {
+	ccnu service.CCNUService
+}
+
+func NewTimetableHandler(ccnu service.CCNUService) *TimetableHandler {
+	return &TimetableHandler{ccnu: ccnu}
+}
+
+func (h *TimetableHandler) Register(srv *khttp.Server) {
+	srv.HandleFunc("/v1/timetable.ics", h.Download)
+}
+
+// Download GET /v1/timetable.ics?token=&year=&term=，只接受会话令牌，不让密码出现在 URL 里
+func (h *TimetableHandler) Download(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	token, year, term := q.Get("token"), q.Get("year"), q.Get("term")
+	if token == "" {
+		http.Error(w, "缺少 token", http.StatusUnauthorized)
+		return
+	}
+	if year == "" || term == "" {
+		http.Error(w, "缺少 year 或 term", http.StatusBadRequest)
+		return
+	}
+	ctx := service.WithSessionToken(r.Context(), token)
+	data, err := h.ccnu.ExportTimetable(ctx, "", "", year, term)
+	if err != nil {
+		e := errors.FromError(err)
+		http.Error(w, e.Message, int(e.Code))
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+service.TimetableFilename(year, term)+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
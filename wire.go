@@ -5,18 +5,34 @@ package main
 import (
 	"github.com/asynccnu/be-ccnu/grpc"
 	"github.com/asynccnu/be-ccnu/ioc"
-	"github.com/asynccnu/be-ccnu/pkg/grpcx"
 	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/web"
 	"github.com/google/wire"
 )
 
-func InitGRPCServer() grpcx.Server {
+func InitApp() *App {
 	wire.Build(
 		ioc.InitGRPCxKratosServer,
 		grpc.NewCCNUServiceServer,
+		ioc.InitCallerAuth,
+		ioc.InitHTTPServer,
+		web.NewTimetableHandler,
 		service.NewCCNUService,
 		ioc.InitLogger,
 		ioc.InitEtcdClient,
+		ioc.InitDB,
+		ioc.InitCredentialVault,
+		ioc.InitLoginLimiter,
+		ioc.InitUpstreamConfig,
+		ioc.InitEgressPool,
+		ioc.InitResilience,
+		ioc.InitKeepAliveConfig,
+		service.NewSessionKeeper,
+		ioc.InitLoginAuditor,
+		ioc.InitUpstreamProber,
+		ioc.InitAcademicCalendar,
+		ioc.InitMetricsServer,
+		wire.Struct(new(App), "*"),
 	)
-	return grpcx.Server(nil)
+	return new(App)
 }
@@ -9,17 +9,41 @@ package main
 import (
 	"github.com/asynccnu/be-ccnu/grpc"
 	"github.com/asynccnu/be-ccnu/ioc"
-	"github.com/asynccnu/be-ccnu/pkg/grpcx"
 	"github.com/asynccnu/be-ccnu/service"
+	"github.com/asynccnu/be-ccnu/web"
 )
 
 // Injectors from wire.go:
 
-func InitGRPCServer() grpcx.Server {
+func InitApp() *App {
+	upstreamConfig := ioc.InitUpstreamConfig()
 	logger := ioc.InitLogger()
-	ccnuService := service.NewCCNUService(logger)
-	ccnuServiceServer := grpc.NewCCNUServiceServer(ccnuService)
+	egressPool := ioc.InitEgressPool(logger)
+	resilience := ioc.InitResilience()
+	db := ioc.InitDB()
+	credentialVault := ioc.InitCredentialVault(db, logger)
+	loginLimiter := ioc.InitLoginLimiter()
+	keepAliveConfig := ioc.InitKeepAliveConfig()
+	loginAuditor := ioc.InitLoginAuditor(db, logger)
+	upstreamProber := ioc.InitUpstreamProber(upstreamConfig, egressPool, resilience, logger)
+	academicCalendar := ioc.InitAcademicCalendar()
+	ccnuService := service.NewCCNUService(upstreamConfig, egressPool, resilience, credentialVault, loginLimiter, keepAliveConfig, loginAuditor, upstreamProber, academicCalendar, logger)
+	callerAuth := ioc.InitCallerAuth()
+	ccnuServiceServer := grpc.NewCCNUServiceServer(ccnuService, callerAuth)
+	timetableHandler := web.NewTimetableHandler(ccnuService)
+	httpServer := ioc.InitHTTPServer(timetableHandler)
 	client := ioc.InitEtcdClient()
-	server := ioc.InitGRPCxKratosServer(ccnuServiceServer, client, logger)
-	return server
+	server := ioc.InitGRPCxKratosServer(ccnuServiceServer, callerAuth, httpServer, client, logger)
+	sessionKeeper := service.NewSessionKeeper(ccnuService, keepAliveConfig, logger)
+	metricsxServer := ioc.InitMetricsServer(upstreamProber, egressPool, logger)
+	app := &App{
+		server:   server,
+		keeper:   sessionKeeper,
+		auditor:  loginAuditor,
+		prober:   upstreamProber,
+		metrics:  metricsxServer,
+		calendar: academicCalendar,
+		l:        logger,
+	}
+	return app
 }